@@ -2,13 +2,12 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"time"
 
+	"github.com/mad-weaver/duck/internal/duckerr"
 	"github.com/mad-weaver/duck/internal/sloghelper"
 	"github.com/urfave/cli/v2"
 )
@@ -20,22 +19,42 @@ func NewApp() *cli.App {
 	app := cli.NewApp()
 	app.Name = "duck"
 	app.Usage = "Duck is a versatile task orchestration tool"
-	app.UsageText = "duck -f <duckfile> [-t <target>] [options]"
+	app.UsageText = "duck -f <duckfile> [-t <target>...] [--tags <tag>...] [options] [command]"
+	app.Description = `Exit codes:
+   0    all targets ran, or the daemon shut down without interrupting a run
+   1    unexpected error
+   2    invalid duckfile or option, nothing was run
+   3    a check failed and cancelled the run or requested an exit
+   4    an action failed and cancelled the run or requested an exit
+   130  interrupted by a termination signal part way through a run
+
+Signals:
+   SIGTERM, SIGINT  start no new targets and give running ones --shutdown-grace seconds to finish
+   SIGHUP           reload the duckfiles (daemon mode)
+   SIGUSR1          run every selected target now, regardless of its schedule (daemon mode)`
 	app.Flags = []cli.Flag{
 		&cli.StringSliceFlag{
-			Name:     "file",
-			Aliases:  []string{"f"},
-			Required: true,
-			Usage:    "specify duckfile as path or URL (can be used multiple times)",
-			EnvVars:  []string{"DUCK_FILE"},
+			Name:    "file",
+			Aliases: []string{"f"},
+			Usage:   "specify duckfile as path or URL (can be used multiple times), required",
+			EnvVars: []string{"DUCK_FILE"},
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:    "target",
 			Aliases: []string{"t"},
-			Value:   "default",
-			Usage:   "specify target to run, default is 'default'",
+			Usage:   "specify target to run by name or glob such as 'db-*' (can be used multiple times), default is 'default' unless --tags is given",
 			EnvVars: []string{"DUCK_TARGET"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "tags",
+			Usage:   "only run selected targets tagged with one of these tags (can be used multiple times)",
+			EnvVars: []string{"DUCK_TAGS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "skip-tags",
+			Usage:   "do not run selected targets tagged with one of these tags (can be used multiple times)",
+			EnvVars: []string{"DUCK_SKIP_TAGS"},
+		},
 		&cli.BoolFlag{
 			Name:    "list-targets",
 			Aliases: []string{"l"},
@@ -43,6 +62,67 @@ func NewApp() *cli.App {
 			Usage:   "list all available targets",
 			EnvVars: []string{"DUCK_LIST_TARGETS"},
 		},
+		&cli.BoolFlag{
+			Name:    "check",
+			Value:   false,
+			Usage:   "run checks normally but only preview what actions would change, skipping actions that cannot preview",
+			EnvVars: []string{"DUCK_CHECK"},
+		},
+		&cli.IntFlag{
+			Name:    "max-parallel",
+			Aliases: []string{"p"},
+			Value:   1,
+			Usage:   "maximum number of independent targets to run at once, 1 runs them one after the other, 0 means no limit",
+			EnvVars: []string{"DUCK_MAX_PARALLEL"},
+			Action: func(ctx *cli.Context, v int) error {
+				if v < 0 {
+					return fmt.Errorf("%w: max-parallel must be greater than or equal to 0", duckerr.ErrConfigInvalid)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:    "shutdown-grace",
+			Value:   30,
+			Usage:   "time in seconds running targets get to finish after SIGTERM or SIGINT before they are cancelled, a second signal cancels them at once",
+			EnvVars: []string{"DUCK_SHUTDOWN_GRACE"},
+		},
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "apply the named profile from the _meta.profiles section of the duckfiles",
+			EnvVars: []string{"DUCK_PROFILE"},
+		},
+		&cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "override a duckfile variable as key=value (can be used multiple times), variables can also be set with DUCK_VAR_<key>",
+			Action: func(ctx *cli.Context, v []string) error {
+				for _, assignment := range v {
+					if key, _, ok := strings.Cut(assignment, "="); !ok || key == "" {
+						return fmt.Errorf("%w: invalid variable assignment: %s -- please use key=value", duckerr.ErrConfigInvalid, assignment)
+					}
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:     "report",
+			Usage:    "write a report of the run to this file, - for stdout (overwritten on every daemon run)",
+			EnvVars:  []string{"DUCK_REPORT"},
+			Category: "Report Options",
+		},
+		&cli.StringFlag{
+			Name:     "report-format",
+			Value:    "json",
+			Usage:    "specify report format (json, junit, tap)",
+			EnvVars:  []string{"DUCK_REPORT_FORMAT"},
+			Category: "Report Options",
+			Action: func(ctx *cli.Context, v string) error {
+				if v != "json" && v != "junit" && v != "tap" {
+					return fmt.Errorf("%w: invalid report format: %s -- please use json, junit or tap", duckerr.ErrConfigInvalid, v)
+				}
+				return nil
+			},
+		},
 		&cli.BoolFlag{
 			Name:     "daemon",
 			Aliases:  []string{"d"},
@@ -59,7 +139,7 @@ func NewApp() *cli.App {
 			Category: "Daemon Control Options",
 			Action: func(ctx *cli.Context, v int) error {
 				if v < 0 {
-					return fmt.Errorf("daemon-timeout must be greater than or equal to 0")
+					return fmt.Errorf("%w: daemon-timeout must be greater than or equal to 0", duckerr.ErrConfigInvalid)
 				}
 				return nil
 			},
@@ -72,7 +152,7 @@ func NewApp() *cli.App {
 			Category: "Daemon Control Options",
 			Action: func(ctx *cli.Context, v int) error {
 				if v < 0 {
-					return fmt.Errorf("daemon-iterations must be greater than or equal to 0")
+					return fmt.Errorf("%w: daemon-iterations must be greater than or equal to 0", duckerr.ErrConfigInvalid)
 				}
 				return nil
 			},
@@ -86,11 +166,30 @@ func NewApp() *cli.App {
 			Category: "Daemon Control Options",
 			Action: func(ctx *cli.Context, v int) error {
 				if v < 0 {
-					return fmt.Errorf("daemon-interval must be greater than 0")
+					return fmt.Errorf("%w: daemon-interval must be greater than 0", duckerr.ErrConfigInvalid)
 				}
 				return nil
 			},
 		},
+		&cli.IntFlag{
+			Name:     "daemon-poll-interval",
+			Value:    60,
+			Usage:    "time in seconds between checks of remote duckfiles for changes, local duckfiles are watched, 0 disables polling",
+			EnvVars:  []string{"DUCK_DAEMON_POLL_INTERVAL"},
+			Category: "Daemon Control Options",
+			Action: func(ctx *cli.Context, v int) error {
+				if v < 0 {
+					return fmt.Errorf("%w: daemon-poll-interval must be greater than or equal to 0", duckerr.ErrConfigInvalid)
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:     "daemon-status",
+			Usage:    "write the last and next run of every target scheduled in daemon mode to this file after each run, - for stdout",
+			EnvVars:  []string{"DUCK_DAEMON_STATUS"},
+			Category: "Daemon Control Options",
+		},
 		&cli.StringFlag{
 			Name:     "loglevel",
 			Value:    "info",
@@ -99,7 +198,7 @@ func NewApp() *cli.App {
 			Category: "Logging Options",
 			Action: func(ctx *cli.Context, v string) error {
 				if v != "debug" && v != "info" && v != "warn" && v != "error" {
-					return fmt.Errorf("invalid log level: %s -- please use debug, info, warn, or error", v)
+					return fmt.Errorf("%w: invalid log level: %s -- please use debug, info, warn, or error", duckerr.ErrConfigInvalid, v)
 				}
 				return nil
 			},
@@ -112,29 +211,39 @@ func NewApp() *cli.App {
 			Category: "Logging Options",
 			Action: func(ctx *cli.Context, v string) error {
 				if v != "json" && v != "text" && v != "rich" {
-					return fmt.Errorf("invalid log format: %s -- please use rich, json or text", v)
+					return fmt.Errorf("%w: invalid log format: %s -- please use rich, json or text", duckerr.ErrConfigInvalid, v)
 				}
 				return nil
 			},
 		},
 	}
+	// Errors about the options themselves wrap duckerr.ErrConfigInvalid so they exit with code 2.
+	// --file is checked in Before rather than marked Required, as cli reports missing required
+	// flags with an error of its own that cannot be told apart from other failures.
+	app.OnUsageError = usageError
 	app.Before = func(c *cli.Context) error {
-		// Create context that listens for interrupt signals
-		ctx, stop := signal.NotifyContext(context.Background(),
-			syscall.SIGTERM,
-			syscall.SIGINT,
-			os.Interrupt)
-
-		// Store the stop func and context in metadata
-		c.App.Metadata = map[string]interface{}{
-			"stop": stop,
-			"ctx":  ctx,
+		if len(c.StringSlice("file")) == 0 {
+			return fmt.Errorf("%w: required flag \"file\" not set", duckerr.ErrConfigInvalid)
 		}
 
 		// Parse CLI args into koanf config - This will be implemented in parse_cli.go
 		konfig, err := ParseCLI(c)
 		if err != nil {
-			return err
+			return fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+		}
+
+		// Create context that shuts down gracefully on termination signals. The grace is checked
+		// here as flag actions only run after Before.
+		grace := konfig.Int("shutdown-grace")
+		if grace < 0 {
+			return fmt.Errorf("%w: shutdown-grace must be greater than or equal to 0", duckerr.ErrConfigInvalid)
+		}
+		ctx, stop := terminationContext(time.Duration(grace) * time.Second)
+
+		// Store the stop func and context in metadata
+		c.App.Metadata = map[string]interface{}{
+			"stop": stop,
+			"ctx":  ctx,
 		}
 
 		// Setup logging using the parsed config
@@ -149,8 +258,62 @@ func NewApp() *cli.App {
 		}
 		return nil
 	}
+	app.Commands = []*cli.Command{
+		{
+			Name:  "plan",
+			Usage: "print the targets, checks and actions a run would execute, without running anything",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Value:   "text",
+					Usage:   "specify plan output format (text, json)",
+					EnvVars: []string{"DUCK_PLAN_OUTPUT"},
+					Action: func(ctx *cli.Context, v string) error {
+						if v != "text" && v != "json" {
+							return fmt.Errorf("%w: invalid output format: %s -- please use text or json", duckerr.ErrConfigInvalid, v)
+						}
+						return nil
+					},
+				},
+			},
+			Action:       PlanApp,
+			OnUsageError: usageError,
+		},
+		{
+			Name:  "graph",
+			Usage: "print the dependency graph of the targets as Graphviz DOT or Mermaid, without running anything",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "format",
+					Value:   "dot",
+					Usage:   "specify graph format (dot, mermaid)",
+					EnvVars: []string{"DUCK_GRAPH_FORMAT"},
+					Action: func(ctx *cli.Context, v string) error {
+						if v != "dot" && v != "mermaid" {
+							return fmt.Errorf("%w: invalid graph format: %s -- please use dot or mermaid", duckerr.ErrConfigInvalid, v)
+						}
+						return nil
+					},
+				},
+				&cli.StringSliceFlag{
+					Name:    "from",
+					Usage:   "only graph this target and the targets it depends on (can be used multiple times)",
+					EnvVars: []string{"DUCK_GRAPH_FROM"},
+				},
+			},
+			Action:       GraphApp,
+			OnUsageError: usageError,
+		},
+	}
 	app.HideHelpCommand = true
 	app.Action = DefaultApp
 
 	return app
 }
+
+// usageError wraps errors parsing the command line, such as an unknown flag or a value of the
+// wrong type, in duckerr.ErrConfigInvalid.
+func usageError(c *cli.Context, err error, isSubcommand bool) error {
+	return fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+}
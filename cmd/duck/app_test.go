@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidOptionsExitWithConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"bad report format", []string{"-f", "main.duck", "--report-format", "bogus"}},
+		{"missing file", []string{"--target", "default"}},
+		{"negative shutdown grace", []string{"-f", "main.duck", "--shutdown-grace", "-1"}},
+		{"unknown flag", []string{"-f", "main.duck", "--bogus"}},
+		{"bad subcommand option", []string{"-f", "main.duck", "plan", "--output", "yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewApp().Run(append([]string{"duck"}, tt.args...))
+			require.ErrorIs(t, err, duckerr.ErrConfigInvalid)
+			require.Equal(t, duckerr.ExitConfigInvalid, duckerr.ExitCode(err))
+		})
+	}
+}
@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"os"
 	"time"
 
 	"github.com/mad-weaver/duck/internal/duck"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
 	"github.com/urfave/cli/v2"
 )
 
@@ -13,65 +17,116 @@ func DefaultApp(c *cli.Context) error {
 	ctx := c.App.Metadata["ctx"].(context.Context)
 	konfig, err := ParseCLI(c)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
 	}
 
-	running := true
-	iterationCount := 0
-	var timeoutCh <-chan time.Time
+	d, err := duck.NewDuck(konfig.Copy())
+	if err != nil {
+		return err
+	}
+	if d.Config.Daemon && !d.Config.ListTargets {
+		return runDaemon(ctx, d)
+	}
 
-	exitch := make(chan struct{})
-	go func() {
-		<-exitch
-		if stop, ok := c.App.Metadata["stop"].(func()); ok {
-			stop()
+	rep, err := d.Run(ctx)
+	if rep != nil && d.Config.Report != "" {
+		if werr := writeReport(d.Config.Report, d.Config.ReportFormat, rep); werr != nil {
+			slog.Error("Failed to write run report", "path", d.Config.Report, "error", werr)
 		}
-	}()
-
-	for running {
-		select {
-		case <-ctx.Done():
-			slog.Info("Received interrupt signal")
-			running = false
-		default:
-			d, err := duck.NewDuck(konfig.Copy())
-			if err != nil {
-				return err
-			}
-			err = d.Run(ctx)
-			if err != nil {
-				return err
-			}
+	}
+	return err
+}
 
-			if konfig.Bool("daemon") {
-				if timeoutCh == nil && konfig.Int("daemon-timeout") > 0 {
-					timeoutCh = time.After(time.Duration(konfig.Int("daemon-timeout")) * time.Second)
-				}
-
-				slog.Debug("Target Run completed, sleeping for specififed interval before next run", "interval", konfig.Int("daemon-interval"))
-				select {
-				case <-ctx.Done():
-					slog.Info("Received interrupt signal, terminating")
-					running = false
-				case <-timeoutCh:
-					slog.Info("Daemon timeout reached, terminating")
-					exitch <- struct{}{}
-					running = false
-				case <-time.After(time.Duration(konfig.Int("daemon-interval")) * time.Second):
-					slog.Debug("Target Run completed, sleeping for specififed interval before next run", "interval", konfig.Int("daemon-interval"))
-					iterationCount++
-					if konfig.Int("daemon-iterations") > 0 && iterationCount >= konfig.Int("daemon-iterations") {
-						slog.Info("Reached maximum number of iterations, terminating")
-						exitch <- struct{}{}
-						running = false
-					}
-
-				}
-			} else {
-				running = false
+// runDaemon compiles the duckfiles once and runs the selected targets on their schedules until
+// the daemon is stopped, reloading the duckfiles whenever they change or SIGHUP is received.
+func runDaemon(ctx context.Context, d *duck.Duck) error {
+	started := time.Now()
+	scheduler, err := duck.NewScheduler(ctx, d)
+	if err != nil {
+		if d.Config.Report != "" {
+			rep := &report.RunReport{Targets: d.Config.Target, Check: d.Config.Check, Started: started, Results: []*report.TargetResult{}}
+			rep.Finish(err)
+			if werr := writeReport(d.Config.Report, d.Config.ReportFormat, rep); werr != nil {
+				slog.Error("Failed to write run report", "path", d.Config.Report, "error", werr)
 			}
 		}
+		return err
 	}
 
+	watcher, err := duck.NewWatcher(time.Duration(d.Config.DaemonPollInterval) * time.Second)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Watch(ctx, d); err != nil {
+		return err
+	}
+	scheduler.Watcher = watcher
+
+	stop := handleDaemonSignals(scheduler)
+	defer stop()
+
+	return runScheduler(ctx, d, scheduler)
+}
+
+// runScheduler runs the selected targets on their own schedules until the daemon is stopped,
+// writing the report after every run and the status of the scheduler whenever it changes.
+func runScheduler(ctx context.Context, d *duck.Duck, scheduler *duck.Scheduler) error {
+	scheduler.Iterations = d.Config.DaemonIterations
+	scheduler.Timeout = time.Duration(d.Config.DaemonTimeout) * time.Second
+	scheduler.OnRun = func(rep *report.RunReport) {
+		if d.Config.Report == "" {
+			return
+		}
+		if err := writeReport(d.Config.Report, d.Config.ReportFormat, rep); err != nil {
+			slog.Error("Failed to write run report", "path", d.Config.Report, "error", err)
+		}
+	}
+	scheduler.OnSchedule = func() {
+		if d.Config.DaemonStatus == "" {
+			return
+		}
+		if err := writeStatus(d.Config.DaemonStatus, scheduler); err != nil {
+			slog.Error("Failed to write daemon status", "path", d.Config.DaemonStatus, "error", err)
+		}
+	}
+	return scheduler.Run(ctx)
+}
+
+// writeReport writes the run report to path in the given format, "-" writes to stdout.
+// The file is replaced on every run so in daemon mode it always holds the latest run.
+func writeReport(path string, format string, rep *report.RunReport) error {
+	if path == "-" {
+		return rep.Write(os.Stdout, format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := rep.Write(f, format); err != nil {
+		return fmt.Errorf("failed to write report file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeStatus writes the status of the scheduler to path, "-" writes to stdout. Like the report
+// the file is replaced every time so it always holds the latest status.
+func writeStatus(path string, scheduler *duck.Scheduler) error {
+	if path == "-" {
+		return scheduler.WriteStatus(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create status file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := scheduler.WriteStatus(f); err != nil {
+		return fmt.Errorf("failed to write status file %s: %w", path, err)
+	}
 	return nil
 }
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mad-weaver/duck/internal/duck"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/urfave/cli/v2"
+)
+
+// GraphApp compiles the duckfiles and prints the target dependency graph as DOT or Mermaid,
+// limited to the targets reachable from --from when it is given.
+func GraphApp(c *cli.Context) error {
+	ctx := c.App.Metadata["ctx"].(context.Context)
+	konfig, err := ParseCLI(c)
+	if err != nil {
+		return fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+
+	d, err := duck.NewDuck(konfig.Copy())
+	if err != nil {
+		return err
+	}
+
+	graph, err := d.Graph(ctx, konfig.Strings("from"))
+	if err != nil {
+		return err
+	}
+
+	switch konfig.String("format") {
+	case "dot":
+		return graph.WriteDOT(os.Stdout)
+	case "mermaid":
+		return graph.WriteMermaid(os.Stdout)
+	default:
+		return fmt.Errorf("invalid graph format: %s -- please use dot or mermaid", konfig.String("format"))
+	}
+}
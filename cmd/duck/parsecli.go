@@ -30,6 +30,8 @@ func ParseCLI(ctx *cli.Context) (*koanf.Koanf, error) {
 		"DUCK_DAEMON_TIMEOUT",
 		"DUCK_DAEMON_ITERATIONS",
 		"DUCK_DAEMON_INTERVAL",
+		"DUCK_DAEMON_STATUS",
+		"DUCK_DAEMON_POLL_INTERVAL",
 		"DUCK_LOGLEVEL",
 		"DUCK_LOGFORMAT",
 		"DUCK_FILE",
@@ -39,11 +41,23 @@ func ParseCLI(ctx *cli.Context) (*koanf.Koanf, error) {
 		"DUCK_CANCEL_ON_CHECK_FAIL",
 		"DUCK_CANCEL_ON_ACTION_FAIL",
 		"DUCK_LIST_TARGETS",
+		"DUCK_MAX_PARALLEL",
+		"DUCK_SHUTDOWN_GRACE",
+		"DUCK_PLAN_OUTPUT",
+		"DUCK_REPORT",
+		"DUCK_REPORT_FORMAT",
+		"DUCK_PROFILE",
+		"DUCK_TAGS",
+		"DUCK_SKIP_TAGS",
+		"DUCK_CHECK",
+		"DUCK_GRAPH_FORMAT",
+		"DUCK_GRAPH_FROM",
 	}
 
 	// push environment variables prefixed with DUCK_ into koanf object
 	if err := konfig.Load(kenv.Provider("DUCK_", ModifiedColon, func(s string) string {
-		if slices.Contains(excludedEnvVars, s) {
+		// DUCK_VAR_* are duckfile variable overrides, read when the duckfile variables are resolved
+		if slices.Contains(excludedEnvVars, s) || strings.HasPrefix(s, "DUCK_VAR_") {
 			return "" // Return an empty string to skip this variable
 		}
 		return strings.Replace(strings.ToLower(strings.TrimPrefix(s, "DUCK_")), "_", ".", -1)
@@ -52,7 +66,7 @@ func ParseCLI(ctx *cli.Context) (*koanf.Koanf, error) {
 	}
 
 	// Push CLI args into koanf object
-	forcedInclude := []string{"loglevel", "list-targets", "logformat", "daemon", "daemon-timeout", "daemon-iterations", "daemon-interval", "target", "file"}
+	forcedInclude := []string{"loglevel", "list-targets", "logformat", "daemon", "daemon-timeout", "daemon-iterations", "daemon-interval", "daemon-poll-interval", "target", "file", "max-parallel", "shutdown-grace", "output", "report-format", "set", "profile", "tags", "skip-tags", "check", "format", "from"}
 	if err := konfig.Load(urfave.NewUrfaveCliProvider(ctx, konfig, ModifiedColon, false, forcedInclude), nil); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mad-weaver/duck/internal/duck"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/urfave/cli/v2"
+)
+
+// PlanApp compiles the duckfiles and prints the execution plan for the selected targets
+// without running any check or action.
+func PlanApp(c *cli.Context) error {
+	ctx := c.App.Metadata["ctx"].(context.Context)
+	konfig, err := ParseCLI(c)
+	if err != nil {
+		return fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+
+	d, err := duck.NewDuck(konfig.Copy())
+	if err != nil {
+		return err
+	}
+
+	plan, err := d.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch konfig.String("output") {
+	case "json":
+		return plan.WriteJSON(os.Stdout)
+	case "text":
+		return plan.WriteText(os.Stdout)
+	default:
+		return fmt.Errorf("invalid output format: %s -- please use text or json", konfig.String("output"))
+	}
+}
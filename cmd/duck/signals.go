@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/duck"
+)
+
+// terminationContext returns the context duck runs with and a func releasing it. The first
+// SIGTERM or SIGINT starts a graceful shutdown, see duck.WithShutdown: nothing new is started and
+// the work already running has grace to finish before the context is cancelled. A second signal
+// cancels the context straight away.
+func terminationContext(grace time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown := make(chan struct{})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			slog.Info("Received termination signal, waiting for running targets to finish", "signal", sig.String(), "grace", grace)
+			close(shutdown)
+		case <-ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			slog.Warn("Shutdown grace period expired, cancelling running targets", "grace", grace)
+		case sig := <-signals:
+			slog.Warn("Received second termination signal, cancelling running targets", "signal", sig.String())
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		cancel()
+	}
+	return duck.WithShutdown(ctx, shutdown), stop
+}
+
+// handleDaemonSignals reloads the duckfiles on SIGHUP and runs every scheduled target on SIGUSR1
+// until the returned func is called.
+func handleDaemonSignals(scheduler *duck.Scheduler) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				slog.Debug("Received daemon control signal", "signal", sig.String())
+				switch sig {
+				case syscall.SIGHUP:
+					scheduler.Reload()
+				case syscall.SIGUSR1:
+					scheduler.RunNow()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -1,13 +1,116 @@
 package actions
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/retry"
+	"github.com/pmezard/go-difflib/difflib"
+)
 
 type Action interface {
 	Execute(context.Context) error // Runs the Action
 	GetConfig() Config             // Returns the Action's configuration
 }
 
+// StatusReporter is implemented by actions that can finish without an error but still report a
+// failed status, such as a shell command exiting non-zero. It is used by retryOn: [failed].
+type StatusReporter interface {
+	Failed() bool // Returns true if the last Execute finished with a failed status
+}
+
+// Result describes what the last Execute of an action did to the system it manages.
+type Result struct {
+	Changed     bool   // true if anything was changed
+	Diff        string // unified diff of the change, empty if the action cannot describe it
+	Description string // the change in words, such as the request a rest action sends
+}
+
+// ChangeReporter is implemented by actions that can tell whether they changed anything, such as
+// a template action rendering the same output again. Actions that don't implement it are
+// assumed to have changed something whenever they succeed.
+type ChangeReporter interface {
+	Result() Result // Returns what the last Execute changed
+}
+
+// DryRunner is implemented by actions that can describe what Execute would do without doing it,
+// such as the diff a template action would write. It is used by check mode, which skips actions
+// that don't implement it.
+type DryRunner interface {
+	DryRun(context.Context) (Result, error) // Returns what Execute would change, changing nothing
+}
+
 type Config struct {
-	CancelOnFailure *bool `mapstructure:"cancelOnFailure"`
-	ExitOnFailure   *bool `mapstructure:"exitOnFailure"`
+	CancelOnFailure *bool         `mapstructure:"cancelOnFailure"`
+	ExitOnFailure   *bool         `mapstructure:"exitOnFailure"`
+	Timeout         time.Duration `mapstructure:"timeout" validate:"min=0"` // bounds each attempt, written with a unit such as "30s", 0 means no timeout
+	Register        string        `mapstructure:"register"`                 // name to store the outcome under, see package vars
+	When            string        `mapstructure:"when"`                     // CEL expression the action only runs if true, see package condition
+	Notify          []string      `mapstructure:"notify"`                   // handlers of the target to run if the action changed something
+	retry.Config    `mapstructure:",squash"`
+}
+
+// Failure describes why a target failed. It is attached to the context of on_failure actions so
+// they can tell what went wrong.
+type Failure struct {
+	Target string // id of the target that failed
+	Reason string // the check or action error that failed the target
+}
+
+type failureKey struct{}
+
+// WithFailure returns a copy of ctx carrying the failure f.
+func WithFailure(ctx context.Context, f Failure) context.Context {
+	return context.WithValue(ctx, failureKey{}, f)
+}
+
+// FailureFromContext returns the failure attached to ctx by WithFailure, if any.
+func FailureFromContext(ctx context.Context) (Failure, bool) {
+	f, ok := ctx.Value(failureKey{}).(Failure)
+	return f, ok
+}
+
+// UnifiedDiff returns a unified diff turning before into after, labelled with name, or an empty
+// string if they are the same.
+func UnifiedDiff(name string, before string, after string) string {
+	if before == after {
+		return ""
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(before),
+		B:        lines(after),
+		FromFile: name,
+		ToFile:   name,
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+type checkModeKey struct{}
+
+// WithCheckMode returns a copy of ctx marking the run as a check mode run, in which actions are
+// previewed with DryRun instead of executed.
+func WithCheckMode(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checkModeKey{}, true)
+}
+
+// CheckMode reports whether ctx was marked by WithCheckMode.
+func CheckMode(ctx context.Context) bool {
+	check, _ := ctx.Value(checkModeKey{}).(bool)
+	return check
+}
+
+// lines splits s into lines that keep their line endings, a missing final newline included.
+func lines(s string) []string {
+	split := strings.SplitAfter(s, "\n")
+	if split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	} else {
+		split[len(split)-1] += "\n"
+	}
+	return split
 }
@@ -10,6 +10,8 @@ import (
 )
 
 var _ actions.Action = (*DummyAction)(nil)
+var _ actions.ChangeReporter = (*DummyAction)(nil)
+var _ actions.DryRunner = (*DummyAction)(nil)
 
 type DummyAction struct {
 	Type   string         `mapstructure:"type"`
@@ -46,3 +48,16 @@ func (a *DummyAction) Execute(ctx context.Context) error {
 func (a *DummyAction) GetConfig() actions.Config {
 	return a.Config
 }
+
+// Result reports that the action never changes anything.
+func (a *DummyAction) Result() actions.Result {
+	return actions.Result{}
+}
+
+// DryRun describes the action, which is safe to preview as it changes nothing.
+func (a *DummyAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+	return actions.Result{Description: "do nothing"}, nil
+}
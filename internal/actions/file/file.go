@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/user"
 	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/actions"
@@ -13,6 +15,8 @@ import (
 )
 
 var _ actions.Action = (*FileAction)(nil)
+var _ actions.ChangeReporter = (*FileAction)(nil)
+var _ actions.DryRunner = (*FileAction)(nil)
 
 type FileAction struct {
 	Type   string         `mapstructure:"type"`
@@ -24,6 +28,7 @@ type FileAction struct {
 		Group *string `mapstructure:"group"`
 		Data  *string `mapstructure:"data"`
 	} `mapstructure:"params"`
+	result actions.Result
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -47,58 +52,152 @@ func (a *FileAction) Execute(ctx context.Context) error {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
-	// Write file contents if specified
+	a.result = actions.Result{}
+
+	// Write file contents if specified and different
 	if a.Params.Data != nil {
-		if err := os.WriteFile(a.Params.Path, []byte(*a.Params.Data), 0644); err != nil {
-			return fmt.Errorf("failed to write file contents: %w", err)
+		before, err := os.ReadFile(a.Params.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read file contents: %w", err)
+		}
+		if err != nil || string(before) != *a.Params.Data {
+			if err := os.WriteFile(a.Params.Path, []byte(*a.Params.Data), 0644); err != nil {
+				return fmt.Errorf("failed to write file contents: %w", err)
+			}
+			a.result = actions.Result{Changed: true, Diff: actions.UnifiedDiff(a.Params.Path, string(before), *a.Params.Data)}
 		}
 	}
 
-	// Change mode if specified
+	info, err := os.Stat(a.Params.Path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	// Change mode if specified and different
 	if a.Params.Mode != nil {
 		mode, err := strconv.ParseUint(*a.Params.Mode, 8, 32)
 		if err != nil {
 			return fmt.Errorf("invalid mode %s: %w", *a.Params.Mode, err)
 		}
-		if err := os.Chmod(a.Params.Path, os.FileMode(mode)); err != nil {
-			return fmt.Errorf("failed to change file mode: %w", err)
+		if info.Mode().Perm() != os.FileMode(mode).Perm() {
+			if err := os.Chmod(a.Params.Path, os.FileMode(mode)); err != nil {
+				return fmt.Errorf("failed to change file mode: %w", err)
+			}
+			a.result.Changed = true
 		}
 	}
 
-	// Change owner/group if either is specified
+	// Change owner/group if either is specified and different
 	if a.Params.Owner != nil || a.Params.Group != nil {
-		var uid, gid = -1, -1 // -1 means "don't change" in os.Chown
-
-		// Resolve owner if specified
-		if a.Params.Owner != nil {
-			u, err := user.Lookup(*a.Params.Owner)
-			if err != nil {
-				return fmt.Errorf("failed to lookup user %s: %w", *a.Params.Owner, err)
-			}
-			uid, err = strconv.Atoi(u.Uid)
-			if err != nil {
-				return fmt.Errorf("invalid uid for user %s: %w", *a.Params.Owner, err)
+		uid, gid, err := a.ids()
+		if err != nil {
+			return err
+		}
+		if !a.owned(info, uid, gid) {
+			if err := os.Chown(a.Params.Path, uid, gid); err != nil {
+				return fmt.Errorf("failed to change file ownership: %w", err)
 			}
+			a.result.Changed = true
 		}
+	}
 
-		// Resolve group if specified
-		if a.Params.Group != nil {
-			g, err := user.LookupGroup(*a.Params.Group)
-			if err != nil {
-				return fmt.Errorf("failed to lookup group %s: %w", *a.Params.Group, err)
-			}
-			gid, err = strconv.Atoi(g.Gid)
-			if err != nil {
-				return fmt.Errorf("invalid gid for group %s: %w", *a.Params.Group, err)
-			}
+	return nil
+}
+
+// DryRun reports which of the contents, mode and ownership of the file Execute would change,
+// with the diff of the contents.
+func (a *FileAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	result := actions.Result{}
+	var changes []string
+	before, err := os.ReadFile(a.Params.Path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to read file contents: %w", err)
+	}
+	if a.Params.Data != nil && (!exists || string(before) != *a.Params.Data) {
+		changes = append(changes, "write contents")
+		result.Diff = actions.UnifiedDiff(a.Params.Path, string(before), *a.Params.Data)
+	}
+
+	var info os.FileInfo
+	if exists {
+		if info, err = os.Stat(a.Params.Path); err != nil {
+			return result, fmt.Errorf("failed to stat file: %w", err)
+		}
+	}
+	if a.Params.Mode != nil {
+		mode, err := strconv.ParseUint(*a.Params.Mode, 8, 32)
+		if err != nil {
+			return result, fmt.Errorf("invalid mode %s: %w", *a.Params.Mode, err)
+		}
+		if info == nil || info.Mode().Perm() != os.FileMode(mode).Perm() {
+			changes = append(changes, "set mode "+*a.Params.Mode)
+		}
+	}
+	if a.Params.Owner != nil || a.Params.Group != nil {
+		uid, gid, err := a.ids()
+		if err != nil {
+			return result, err
+		}
+		if info == nil || !a.owned(info, uid, gid) {
+			changes = append(changes, "change ownership")
+		}
+	}
+
+	if len(changes) == 0 {
+		result.Description = fmt.Sprintf("%s is up to date", a.Params.Path)
+		return result, nil
+	}
+	result.Changed = true
+	result.Description = fmt.Sprintf("%s of %s", strings.Join(changes, ", "), a.Params.Path)
+	return result, nil
+}
+
+// ids resolves the owner and group of the file to numeric ids, -1 for the ones not specified,
+// which os.Chown leaves unchanged.
+func (a *FileAction) ids() (int, int, error) {
+	var uid, gid = -1, -1
+
+	// Resolve owner if specified
+	if a.Params.Owner != nil {
+		u, err := user.Lookup(*a.Params.Owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to lookup user %s: %w", *a.Params.Owner, err)
+		}
+		uid, err = strconv.Atoi(u.Uid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid uid for user %s: %w", *a.Params.Owner, err)
 		}
+	}
 
-		if err := os.Chown(a.Params.Path, uid, gid); err != nil {
-			return fmt.Errorf("failed to change file ownership: %w", err)
+	// Resolve group if specified
+	if a.Params.Group != nil {
+		g, err := user.LookupGroup(*a.Params.Group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to lookup group %s: %w", *a.Params.Group, err)
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid gid for group %s: %w", *a.Params.Group, err)
 		}
 	}
 
-	return nil
+	return uid, gid, nil
+}
+
+// owned reports whether the file described by info already has the given owner and group.
+func (a *FileAction) owned(info os.FileInfo, uid int, gid int) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && (uid == -1 || uint32(uid) == stat.Uid) && (gid == -1 || uint32(gid) == stat.Gid)
+}
+
+// Result reports whether the last Execute changed the contents, mode or ownership of the file.
+func (a *FileAction) Result() actions.Result {
+	return a.result
 }
 
 func (a *FileAction) GetConfig() actions.Config {
@@ -13,6 +13,8 @@ import (
 )
 
 var _ actions.Action = (*LocalStateAction)(nil)
+var _ actions.ChangeReporter = (*LocalStateAction)(nil)
+var _ actions.DryRunner = (*LocalStateAction)(nil)
 
 type LocalStateAction struct {
 	Type   string         `mapstructure:"type"`
@@ -24,6 +26,7 @@ type LocalStateAction struct {
 		State     string `mapstructure:"state"`
 		WipeState bool   `mapstructure:"wipe_state" default:"false"`
 	} `mapstructure:"params"`
+	result actions.Result
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -50,7 +53,16 @@ func (a *LocalStateAction) Execute(ctx context.Context) error {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
-	filePath := filepath.Join(a.Params.Path, a.Params.IdPrefix+a.Params.Id)
+	filePath := a.filePath()
+	result, err := a.DryRun(ctx)
+	a.result = actions.Result{}
+	if err != nil {
+		return err
+	}
+	if !result.Changed {
+		slog.Debug("State file already up to date", "path", filePath)
+		return nil
+	}
 
 	if a.Params.WipeState {
 		slog.Debug("Removing state file", "path", filePath)
@@ -76,9 +88,49 @@ func (a *LocalStateAction) Execute(ctx context.Context) error {
 		slog.Debug("State written to file", "path", filePath)
 	}
 
+	a.result = result
 	return nil
 }
 
+// DryRun reports whether Execute would create, update or remove the state file, with the diff.
+func (a *LocalStateAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	filePath := a.filePath()
+	before, err := os.ReadFile(filePath)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return actions.Result{}, fmt.Errorf("failed to read state file %s: %w", filePath, err)
+	}
+
+	switch {
+	case a.Params.WipeState && !existed:
+		return actions.Result{Description: fmt.Sprintf("state file %s does not exist", filePath)}, nil
+	case a.Params.WipeState:
+		return actions.Result{Changed: true, Diff: actions.UnifiedDiff(filePath, string(before), ""),
+			Description: fmt.Sprintf("delete state file %s", filePath)}, nil
+	case existed && string(before) == a.Params.State:
+		return actions.Result{Description: fmt.Sprintf("state file %s is up to date", filePath)}, nil
+	case existed:
+		return actions.Result{Changed: true, Diff: actions.UnifiedDiff(filePath, string(before), a.Params.State),
+			Description: fmt.Sprintf("update state file %s", filePath)}, nil
+	default:
+		return actions.Result{Changed: true, Diff: actions.UnifiedDiff(filePath, "", a.Params.State),
+			Description: fmt.Sprintf("create state file %s", filePath)}, nil
+	}
+}
+
+func (a *LocalStateAction) filePath() string {
+	return filepath.Join(a.Params.Path, a.Params.IdPrefix+a.Params.Id)
+}
+
+// Result reports whether the last Execute wrote or removed the state file.
+func (a *LocalStateAction) Result() actions.Result {
+	return a.result
+}
+
 func (a *LocalStateAction) GetConfig() actions.Config {
 	return a.Config
 }
@@ -10,6 +10,8 @@ import (
 )
 
 var _ actions.Action = (*PrintAction)(nil)
+var _ actions.ChangeReporter = (*PrintAction)(nil)
+var _ actions.DryRunner = (*PrintAction)(nil)
 
 type PrintAction struct {
 	Type   string         `mapstructure:"type"`
@@ -51,3 +53,16 @@ func (a *PrintAction) Execute(ctx context.Context) error {
 func (a *PrintAction) GetConfig() actions.Config {
 	return a.Config
 }
+
+// Result reports that the action never changes anything.
+func (a *PrintAction) Result() actions.Result {
+	return actions.Result{}
+}
+
+// DryRun describes the action, which is safe to preview as it changes nothing.
+func (a *PrintAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+	return actions.Result{Description: fmt.Sprintf("print %q", a.Params.Message)}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,9 +15,14 @@ import (
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/actions"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/resthelper"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 var _ actions.Action = (*RestAction)(nil)
+var _ actions.StatusReporter = (*RestAction)(nil)
+var _ vars.OutputReporter = (*RestAction)(nil)
+var _ actions.DryRunner = (*RestAction)(nil)
 
 type RestAction struct {
 	Type   string         `mapstructure:"type"`
@@ -37,7 +43,8 @@ type RestAction struct {
 			CAFile             string `mapstructure:"ca_file" validate:"omitempty,file"`
 		} `mapstructure:"tls"`
 	} `mapstructure:"params"`
-	client *resty.Client
+	client   *resty.Client
+	response *resty.Response
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -155,16 +162,61 @@ func (a *RestAction) Execute(ctx context.Context) error {
 		return fmt.Errorf("unsupported HTTP method: %s", method)
 	}
 
+	a.response = nil
 	response, err := fn(resp, a.Params.URL)
 	if err != nil {
 		return fmt.Errorf("HTTP request failed: %w", err)
 	}
 
-	slog.Debug("Rest call returned", "status_code", response.StatusCode)
+	a.response = response
+	slog.Debug("Rest call returned", "status_code", response.StatusCode())
 
 	return nil
 }
 
+// DryRun describes the request Execute would send, without sending it. Header values and the
+// password are left out as they often carry credentials.
+func (a *RestAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	method := strings.ToUpper(a.Params.Method)
+	if _, ok := methodMap[method]; !ok {
+		return actions.Result{}, fmt.Errorf("unsupported HTTP method: %s", method)
+	}
+
+	description := fmt.Sprintf("send %s %s", method, a.Params.URL)
+	var headers []string
+	if a.Params.ContentType != "" {
+		headers = append(headers, "Content-Type")
+	}
+	for header := range a.Params.Headers {
+		headers = append(headers, header)
+	}
+	if a.Params.BasicUsername != "" && a.Params.BasicPassword != "" {
+		headers = append(headers, "Authorization")
+	}
+	if len(headers) > 0 {
+		slices.Sort(headers)
+		description += fmt.Sprintf(" with headers %s", strings.Join(headers, ", "))
+	}
+	if a.Params.Body != "" {
+		description += fmt.Sprintf(" and a %d byte body", len(a.Params.Body))
+	}
+	return actions.Result{Changed: true, Description: description}, nil
+}
+
+// Failed reports whether the last request returned a 4xx or 5xx status code.
+func (a *RestAction) Failed() bool {
+	return a.response != nil && a.response.StatusCode() >= 400
+}
+
 func (a *RestAction) GetConfig() actions.Config {
 	return a.Config
 }
+
+// Output returns the status code, body and headers of the last response.
+func (a *RestAction) Output() map[string]interface{} {
+	return resthelper.Output(a.response)
+}
@@ -5,16 +5,23 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-cmd/cmd"
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/actions"
+	"github.com/mad-weaver/duck/internal/cmdhelper"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 var _ actions.Action = (*ShellAction)(nil)
+var _ actions.StatusReporter = (*ShellAction)(nil)
+var _ vars.OutputReporter = (*ShellAction)(nil)
+var _ actions.DryRunner = (*ShellAction)(nil)
 
 type ShellAction struct {
 	Type   string         `mapstructure:"type"`
@@ -28,7 +35,9 @@ type ShellAction struct {
 		Dir          string            `mapstructure:"dir" default:""`
 		Echo         bool              `mapstructure:"echo" default:"false"`
 	} `mapstructure:"params"`
-	command *cmd.Cmd
+	command  *cmd.Cmd // template for every run, cloned in Execute so the action can be retried
+	exitCode int
+	stdout   []string
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -81,23 +90,34 @@ func (a *ShellAction) Execute(ctx context.Context) error {
 	}
 
 	slog.Debug("Executing command", "command", a.Params.Command)
-	sChan := a.command.Start()
+	command := a.command.Clone()
+	if failure, ok := actions.FailureFromContext(ctx); ok {
+		// Clone copies the template's Env slice header only, so appending to it could write
+		// into the template's backing array.
+		command.Env = append(slices.Clone(command.Env),
+			fmt.Sprintf("DUCK_FAILURE_TARGET=%s", failure.Target),
+			fmt.Sprintf("DUCK_FAILURE_REASON=%s", failure.Reason))
+	}
+	sChan := command.Start()
 
 	go func() {
 		select {
 		case <-time.After(time.Duration(a.Params.Timeout) * time.Second):
 			slog.Error("Command timed out", "command", a.Params.Command)
-			a.command.Stop()
+			cmdhelper.Stop(command)
 			return
 		case <-ctx.Done():
 			slog.Debug("Command cancelled", "command", a.Params.Command)
-			a.command.Stop()
+			cmdhelper.Stop(command)
+			return
+		case <-command.Done():
 			return
 		}
 	}()
 
 	s1 := <-sChan
 	slog.Debug("Command completed", "command", a.Params.Command)
+	a.stdout = s1.Stdout
 
 	if a.Params.Echo && len(s1.Stdout) > 0 {
 		fmt.Println(strings.Join(s1.Stdout, "\n"))
@@ -108,6 +128,7 @@ func (a *ShellAction) Execute(ctx context.Context) error {
 		return s1.Error
 	}
 
+	a.exitCode = s1.Exit
 	if s1.Exit != 0 {
 		slog.Debug("Command exited with non-zero status", "exit_code", s1.Exit)
 	}
@@ -115,6 +136,36 @@ func (a *ShellAction) Execute(ctx context.Context) error {
 	return nil
 }
 
+// DryRun describes the command line Execute would run, without running it.
+func (a *ShellAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	line := []string{strconv.Quote(a.Params.Command)}
+	for _, arg := range a.Params.Args {
+		line = append(line, strconv.Quote(arg))
+	}
+	description := "run " + strings.Join(line, " ")
+	if a.Params.Dir != "" {
+		description += " in " + a.Params.Dir
+	}
+	return actions.Result{Changed: true, Description: description}, nil
+}
+
+// Failed reports whether the last run of the command exited with a non-zero status.
+func (a *ShellAction) Failed() bool {
+	return a.exitCode != 0
+}
+
+// Output returns the combined stdout and stderr and the exit code of the last run.
+func (a *ShellAction) Output() map[string]interface{} {
+	return map[string]interface{}{
+		"stdout":    strings.Join(a.stdout, "\n"),
+		"exit_code": a.exitCode,
+	}
+}
+
 func (a *ShellAction) GetConfig() actions.Config {
 	return a.Config
 }
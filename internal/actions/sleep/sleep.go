@@ -11,6 +11,8 @@ import (
 )
 
 var _ actions.Action = (*SleepAction)(nil)
+var _ actions.ChangeReporter = (*SleepAction)(nil)
+var _ actions.DryRunner = (*SleepAction)(nil)
 
 type SleepAction struct {
 	Type   string         `mapstructure:"type"`
@@ -57,3 +59,16 @@ func (a *SleepAction) Execute(ctx context.Context) error {
 func (a *SleepAction) GetConfig() actions.Config {
 	return a.Config
 }
+
+// Result reports that the action never changes anything.
+func (a *SleepAction) Result() actions.Result {
+	return actions.Result{}
+}
+
+// DryRun describes the action, which is safe to preview as it changes nothing.
+func (a *SleepAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+	return actions.Result{Description: fmt.Sprintf("sleep %ds", a.Params.Seconds)}, nil
+}
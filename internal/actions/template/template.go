@@ -21,6 +21,8 @@ import (
 )
 
 var _ actions.Action = (*TemplateAction)(nil)
+var _ actions.ChangeReporter = (*TemplateAction)(nil)
+var _ actions.DryRunner = (*TemplateAction)(nil)
 
 type TemplateAction struct {
 	Type   string         `mapstructure:"type"`
@@ -35,6 +37,7 @@ type TemplateAction struct {
 		InsecureSkipVerify bool              `mapstructure:"insecure_skip_verify" default:"false"`                         // For fetching remote sources
 	} `mapstructure:"params"`
 	client *resty.Client
+	result actions.Result
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -83,16 +86,13 @@ func (a *TemplateAction) fetchContent(source string) ([]byte, error) {
 	return content, nil
 }
 
-func (a *TemplateAction) Execute(ctx context.Context) error {
-	if err := ctx.Err(); err != nil {
-		return fmt.Errorf("context cancelled before execution: %w", err)
-	}
-
+// render fetches the template and its data and returns the rendered output.
+func (a *TemplateAction) render() ([]byte, error) {
 	// Fetch template content
 	slog.Debug("Fetching template", "source", a.Params.TemplateSource)
 	templateContent, err := a.fetchContent(a.Params.TemplateSource)
 	if err != nil {
-		return fmt.Errorf("failed to get template content: %w", err)
+		return nil, fmt.Errorf("failed to get template content: %w", err)
 	}
 
 	// Prepare data map
@@ -106,7 +106,7 @@ func (a *TemplateAction) Execute(ctx context.Context) error {
 			slog.Debug("Fetching data source", "source", a.Params.DataSource)
 			dataSourceContent, err = a.fetchContent(a.Params.DataSource)
 			if err != nil {
-				return fmt.Errorf("failed to get data source content: %w", err)
+				return nil, fmt.Errorf("failed to get data source content: %w", err)
 			}
 		}
 
@@ -114,14 +114,14 @@ func (a *TemplateAction) Execute(ctx context.Context) error {
 		switch strings.ToLower(a.Params.DataSourceFormat) {
 		case "json":
 			if err := json.Unmarshal(dataSourceContent, &dataMap); err != nil {
-				return fmt.Errorf("failed to parse JSON data source: %w", err)
+				return nil, fmt.Errorf("failed to parse JSON data source: %w", err)
 			}
 		case "yaml":
 			if err := yaml.Unmarshal(dataSourceContent, &dataMap); err != nil {
-				return fmt.Errorf("failed to parse YAML data source: %w", err)
+				return nil, fmt.Errorf("failed to parse YAML data source: %w", err)
 			}
 		default:
-			return fmt.Errorf("unsupported data source format: %s", a.Params.DataSourceFormat)
+			return nil, fmt.Errorf("unsupported data source format: %s", a.Params.DataSourceFormat)
 		}
 	}
 
@@ -129,13 +129,37 @@ func (a *TemplateAction) Execute(ctx context.Context) error {
 	slog.Debug("Parsing template", "template_name", filepath.Base(a.Params.TemplateSource))
 	tmpl, err := template.New(filepath.Base(a.Params.TemplateSource)).Parse(string(templateContent))
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
 	var renderedOutput bytes.Buffer
 	slog.Debug("Executing template")
 	if err := tmpl.Execute(&renderedOutput, dataMap); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return renderedOutput.Bytes(), nil
+}
+
+func (a *TemplateAction) Execute(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	rendered, err := a.render()
+	if err != nil {
+		return err
+	}
+
+	// Leave the output alone when it already holds the rendered content
+	a.result = actions.Result{}
+	result, err := a.compare(rendered)
+	if err != nil {
+		return err
+	}
+	if !result.Changed {
+		slog.Info("Template output already up to date", "output_path", a.Params.OutputPath)
+		return nil
 	}
 
 	// Write output to file
@@ -146,14 +170,50 @@ func (a *TemplateAction) Execute(ctx context.Context) error {
 	}
 
 	slog.Debug("Writing rendered output to file", "path", a.Params.OutputPath)
-	if err := os.WriteFile(a.Params.OutputPath, renderedOutput.Bytes(), 0644); err != nil {
+	if err := os.WriteFile(a.Params.OutputPath, rendered, 0644); err != nil {
 		return fmt.Errorf("failed to write output file %s: %w", a.Params.OutputPath, err)
 	}
 
+	a.result = result
 	slog.Info("Template rendered successfully", "output_path", a.Params.OutputPath)
 	return nil
 }
 
+// DryRun renders the template and reports the diff Execute would write to the output file.
+func (a *TemplateAction) DryRun(ctx context.Context) (actions.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Result{}, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	rendered, err := a.render()
+	if err != nil {
+		return actions.Result{}, err
+	}
+	return a.compare(rendered)
+}
+
+// compare reports how rendered differs from the current output file.
+func (a *TemplateAction) compare(rendered []byte) (actions.Result, error) {
+	existing, err := os.ReadFile(a.Params.OutputPath)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return actions.Result{}, fmt.Errorf("failed to read output file %s: %w", a.Params.OutputPath, err)
+	case err != nil:
+		return actions.Result{Changed: true, Diff: actions.UnifiedDiff(a.Params.OutputPath, "", string(rendered)),
+			Description: fmt.Sprintf("create %s", a.Params.OutputPath)}, nil
+	case bytes.Equal(existing, rendered):
+		return actions.Result{Description: fmt.Sprintf("%s is up to date", a.Params.OutputPath)}, nil
+	default:
+		return actions.Result{Changed: true, Diff: actions.UnifiedDiff(a.Params.OutputPath, string(existing), string(rendered)),
+			Description: fmt.Sprintf("update %s", a.Params.OutputPath)}, nil
+	}
+}
+
+// Result reports whether the last Execute wrote the output file.
+func (a *TemplateAction) Result() actions.Result {
+	return a.result
+}
+
 func (a *TemplateAction) GetConfig() actions.Config {
 	return a.Config
 }
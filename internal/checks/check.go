@@ -1,6 +1,12 @@
 package checks
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/mad-weaver/duck/internal/retry"
+)
 
 type Check interface {
 	Execute(context.Context) error // Runs the Check
@@ -8,8 +14,17 @@ type Check interface {
 	GetConfig() Config             // Returns the Check's configuration
 }
 
+// ChildReporter is implemented by checks made up of other checks, such as group, so the outcome
+// of each child can be included in the run report.
+type ChildReporter interface {
+	Children() []report.CheckResult // Returns the outcome of every child from the last Execute
+}
+
 type Config struct {
-	Invert          bool  `default:"false"`
-	CancelOnFailure *bool `mapstructure:"cancelOnFailure"`
-	ExitOnFailure   *bool `mapstructure:"exitOnFailure"`
+	Invert          bool          `default:"false"`
+	CancelOnFailure *bool         `mapstructure:"cancelOnFailure"`
+	ExitOnFailure   *bool         `mapstructure:"exitOnFailure"`
+	Timeout         time.Duration `mapstructure:"timeout" validate:"min=0"` // bounds each attempt, written with a unit such as "30s", 0 means no timeout
+	Register        string        `mapstructure:"register"`                 // name to store the outcome under, see package vars
+	retry.Config    `mapstructure:",squash"`
 }
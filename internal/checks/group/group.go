@@ -0,0 +1,182 @@
+package groupcheck
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/checks"
+	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/report"
+)
+
+var _ checks.Check = (*GroupCheck)(nil)
+var _ checks.ChildReporter = (*GroupCheck)(nil)
+
+// Loader loads a single check from its duckfile definition, it is how the group builds its
+// children without knowing every check type. Target.LoadCheck satisfies it.
+type Loader func(context.Context, *koanf.Koanf) (checks.Check, error)
+
+var atLeastMode = regexp.MustCompile(`^atLeast\((\d+)\)$`)
+
+// GroupCheck combines the outcome of nested checks. Mode decides how:
+//   - all: passes when every child passes
+//   - any: passes when at least one child passes
+//   - none: passes when no child passes
+//   - atLeast(n): passes when n or more children pass
+//
+// Children run in order and evaluation stops as soon as the outcome is known, the children that
+// were not evaluated are reported as skipped. A child that fails to execute, such as a REST check
+// against a service that is down, is reported as an error and counts as not passing; only a
+// cancelled context makes the group fail to execute. Children honour their own invert and timeout
+// settings, retries are left to the group.
+type GroupCheck struct {
+	Type   string        `mapstructure:"type"`
+	Status bool          `default:"false"`
+	Config checks.Config `mapstructure:"config"`
+	Params struct {
+		Mode   string                   `mapstructure:"mode" default:"all" validate:"required"`
+		Checks []map[string]interface{} `mapstructure:"checks"` // raw child definitions, loaded through the Loader
+	} `mapstructure:"params"`
+	checks   []checks.Check
+	types    []string
+	need     int // number of passing children required by atLeast
+	children []report.CheckResult
+}
+
+var configHelper = confighelper.GetConfigHelper()
+
+// NewCheck creates a new GroupCheck. It takes a koanf object to
+// hydrate the check struct and a loader for the checks listed in params.checks.
+// It consumes the whole koanf object, so you likely want to
+// carve it off a larger koanf object.
+func NewCheck(ctx context.Context, konfig *koanf.Koanf, load Loader) (*GroupCheck, error) {
+	c := &GroupCheck{}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	if err := configHelper.Load(c, konfig, "", "mapstructure"); err != nil {
+		return nil, err
+	}
+
+	switch c.Params.Mode {
+	case "all", "any", "none":
+	default:
+		m := atLeastMode.FindStringSubmatch(c.Params.Mode)
+		if m == nil {
+			return nil, fmt.Errorf("invalid group mode %q, must be one of all, any, none or atLeast(n)", c.Params.Mode)
+		}
+		c.need, _ = strconv.Atoi(m[1])
+	}
+
+	for i, child := range konfig.Cut("params").Slices("checks") {
+		childType := child.String("type")
+		if register := child.String("config" + child.Delim() + "register"); register != "" {
+			return nil, fmt.Errorf("check %d of group registers %s, but checks inside a group never register, register the group instead", i, register)
+		}
+		check, err := load(ctx, child)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load check %d of group: %w", i, err)
+		}
+		c.checks = append(c.checks, check)
+		c.types = append(c.types, childType)
+	}
+	if len(c.checks) == 0 {
+		return nil, fmt.Errorf("group check requires at least one check in params.checks")
+	}
+	if c.need > len(c.checks) {
+		return nil, fmt.Errorf("group mode %s can never pass with %d checks", c.Params.Mode, len(c.checks))
+	}
+
+	return c, nil
+}
+
+func (c *GroupCheck) Execute(ctx context.Context) error {
+	c.Status = false
+	c.children = make([]report.CheckResult, 0, len(c.checks))
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	passed := 0
+	decided := false
+	for i, check := range c.checks {
+		result := report.CheckResult{Index: i, Type: c.types[i], Invert: check.GetConfig().Invert, Attempts: 1}
+
+		if decided {
+			result.Status, result.Attempts = report.StatusSkipped, 0
+			c.children = append(c.children, result)
+			continue
+		}
+
+		started := time.Now()
+		err := c.executeChild(ctx, check)
+		result.Duration = time.Since(started).Seconds()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			result.Status, result.Error = report.StatusError, err.Error()
+			c.children = append(c.children, result)
+			return fmt.Errorf("check %d (%s) of group: %w", i, c.types[i], err)
+		case err != nil:
+			result.Status, result.Error = report.StatusError, err.Error()
+			slog.Warn("Group child failed to execute, counting it as not passed", "mode", c.Params.Mode, "check", i, "type", c.types[i], "error", err)
+		case check.Check():
+			result.Status = report.StatusPassed
+			passed++
+		default:
+			result.Status = report.StatusFailed
+		}
+		c.children = append(c.children, result)
+		slog.Debug("Group child evaluated", "mode", c.Params.Mode, "check", i, "type", c.types[i], "status", result.Status)
+
+		c.Status, decided = c.evaluate(passed, i+1)
+	}
+
+	return nil
+}
+
+// evaluate returns the status of the group after the first evaluated children were run with
+// passed of them passing, and whether the remaining children can still change it.
+func (c *GroupCheck) evaluate(passed int, evaluated int) (bool, bool) {
+	failed := evaluated - passed
+	remaining := len(c.checks) - evaluated
+	switch c.Params.Mode {
+	case "all":
+		return failed == 0, failed > 0 || remaining == 0
+	case "any":
+		return passed > 0, passed > 0 || remaining == 0
+	case "none":
+		return passed == 0, passed > 0 || remaining == 0
+	default:
+		return passed >= c.need, passed >= c.need || passed+remaining < c.need
+	}
+}
+
+func (c *GroupCheck) executeChild(ctx context.Context, check checks.Check) error {
+	if timeout := check.GetConfig().Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return check.Execute(ctx)
+}
+
+func (c *GroupCheck) Check() bool {
+	return (c.Status != c.Config.Invert)
+}
+
+func (c *GroupCheck) GetConfig() checks.Config {
+	return c.Config
+}
+
+// Children returns the outcome of every child from the last Execute.
+func (c *GroupCheck) Children() []report.CheckResult {
+	return c.children
+}
@@ -0,0 +1,91 @@
+package groupcheck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/checks"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+// stubCheck passes, fails or errors depending on its type.
+type stubCheck struct {
+	kind   string
+	status bool
+}
+
+func (s *stubCheck) Execute(ctx context.Context) error {
+	if s.kind == "error" {
+		return errors.New("service unavailable")
+	}
+	s.status = s.kind == "pass"
+	return nil
+}
+
+func (s *stubCheck) Check() bool              { return s.status }
+func (s *stubCheck) GetConfig() checks.Config { return checks.Config{} }
+
+func stubLoader(ctx context.Context, k *koanf.Koanf) (checks.Check, error) {
+	return &stubCheck{kind: k.String("type")}, nil
+}
+
+func newGroup(t *testing.T, mode string, kinds ...string) *GroupCheck {
+	t.Helper()
+	k := koanf.New(".")
+	definition := "type: group\nparams:\n  mode: " + mode + "\n  checks: [{type: " + strings.Join(kinds, "}, {type: ") + "}]\n"
+	require.NoError(t, k.Load(rawbytes.Provider([]byte(definition)), yaml.Parser()))
+
+	c, err := NewCheck(context.Background(), k, stubLoader)
+	require.NoError(t, err)
+	return c
+}
+
+func TestChildErrorCountsAsNotPassed(t *testing.T) {
+	tests := []struct {
+		mode     string
+		kinds    []string
+		expected bool
+		statuses []report.Status
+	}{
+		{"any", []string{"error", "pass"}, true, []report.Status{report.StatusError, report.StatusPassed}},
+		{"any", []string{"error", "error"}, false, []report.Status{report.StatusError, report.StatusError}},
+		{"none", []string{"error", "fail"}, true, []report.Status{report.StatusError, report.StatusFailed}},
+		{"atLeast(1)", []string{"error", "pass", "pass"}, true, []report.Status{report.StatusError, report.StatusPassed, report.StatusSkipped}},
+		{"all", []string{"error", "pass"}, false, []report.Status{report.StatusError, report.StatusSkipped}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			c := newGroup(t, tt.mode, tt.kinds...)
+			require.NoError(t, c.Execute(context.Background()))
+			require.Equal(t, tt.expected, c.Check())
+
+			var statuses []report.Status
+			for _, child := range c.Children() {
+				statuses = append(statuses, child.Status)
+			}
+			require.Equal(t, tt.statuses, statuses)
+		})
+	}
+}
+
+func TestCancelledContextAbortsGroup(t *testing.T) {
+	c := newGroup(t, "any", "pass")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, c.Execute(ctx), context.Canceled)
+}
+
+func TestChildRegisterIsRejected(t *testing.T) {
+	k := koanf.New(".")
+	definition := "type: group\nparams:\n  checks: [{type: pass}, {type: pass, config: {register: inner}}]\n"
+	require.NoError(t, k.Load(rawbytes.Provider([]byte(definition)), yaml.Parser()))
+
+	_, err := NewCheck(context.Background(), k, stubLoader)
+	require.ErrorContains(t, err, "check 1 of group registers inner")
+}
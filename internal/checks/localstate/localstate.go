@@ -48,6 +48,8 @@ func (c *LocalStateCheck) Execute(ctx context.Context) error {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
+	c.Status = false
+
 	filePath := filepath.Join(c.Params.Path, c.Params.IdPrefix+c.Params.Id)
 
 	contentBytes, err := os.ReadFile(filePath)
@@ -13,9 +13,12 @@ import (
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/checks"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/resthelper"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 var _ checks.Check = (*RestCheck)(nil)
+var _ vars.OutputReporter = (*RestCheck)(nil)
 
 type RestCheck struct {
 	Type   string        `mapstructure:"type"`
@@ -39,7 +42,8 @@ type RestCheck struct {
 			CAFile             string `mapstructure:"ca_file" validate:"omitempty,file"`
 		} `mapstructure:"tls"`
 	} `mapstructure:"params"`
-	client *resty.Client
+	client   *resty.Client
+	response *resty.Response
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -115,6 +119,9 @@ func (c *RestCheck) Execute(ctx context.Context) error {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
+	c.Status = false
+	c.response = nil
+
 	// Configure timeout if specified
 	if c.Params.Timeout > 0 {
 		c.client.SetTimeout(time.Duration(c.Params.Timeout) * time.Second)
@@ -161,6 +168,7 @@ func (c *RestCheck) Execute(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("HTTP request failed: %w", err)
 	}
+	c.response = response
 
 	if response.StatusCode() != c.Params.ExpectCode && c.Params.ExpectCode != 0 {
 		return fmt.Errorf("unexpected status code: got %d, want %d", response.StatusCode(), c.Params.ExpectCode)
@@ -186,3 +194,8 @@ func (c *RestCheck) Check() bool {
 func (c *RestCheck) GetConfig() checks.Config {
 	return c.Config
 }
+
+// Output returns the status code, body and headers of the last response.
+func (c *RestCheck) Output() map[string]interface{} {
+	return resthelper.Output(c.response)
+}
@@ -2,20 +2,23 @@ package shellcheck
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-cmd/cmd"
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/checks"
+	"github.com/mad-weaver/duck/internal/cmdhelper"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 var _ checks.Check = (*ShellCheck)(nil)
+var _ vars.OutputReporter = (*ShellCheck)(nil)
 
 type ShellCheck struct {
 	Type   string        `mapstructure:"type"`
@@ -33,7 +36,9 @@ type ShellCheck struct {
 		Echo         bool              `mapstructure:"echo" default:"false"`
 		Dir          string            `mapstructure:"dir" default:""`
 	} `mapstructure:"params"`
-	command *cmd.Cmd
+	command  *cmd.Cmd // template for every run, cloned in Execute so the check can be retried
+	exitCode int
+	stdout   []string
 }
 
 var configHelper = confighelper.GetConfigHelper()
@@ -76,29 +81,30 @@ func (c *ShellCheck) Execute(ctx context.Context) error {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
-	if c.command.Status().Runtime > 0 {
-		slog.Error("Command being erroneously retriggered. cancelling")
-		return errors.New("command being erroneously retriggered. cancelling")
-	}
+	c.Status = false
 
 	slog.Debug("Executing command", "command", c.Params.Command)
-	sChan := c.command.Start()
+	command := c.command.Clone()
+	sChan := command.Start()
 
 	go func() {
 		select {
 		case <-time.After(time.Duration(c.Params.Timeout) * time.Second):
 			slog.Error("Command timed out", "command", c.Params.Command)
-			c.command.Stop()
+			cmdhelper.Stop(command)
 			return
 		case <-ctx.Done():
 			slog.Debug("Command cancelled", "command", c.Params.Command)
-			c.command.Stop()
+			cmdhelper.Stop(command)
+			return
+		case <-command.Done():
 			return
 		}
 	}()
 
 	s1 := <-sChan
 	slog.Debug("Command completed", "command", c.Params.Command)
+	c.exitCode, c.stdout = s1.Exit, s1.Stdout
 
 	if s1.Error != nil {
 		slog.Error("Command failed to run with error", "error", s1.Error)
@@ -163,3 +169,11 @@ func (c *ShellCheck) Check() bool {
 func (c *ShellCheck) GetConfig() checks.Config {
 	return c.Config
 }
+
+// Output returns the combined stdout and stderr and the exit code of the last run.
+func (c *ShellCheck) Output() map[string]interface{} {
+	return map[string]interface{}{
+		"stdout":    strings.Join(c.stdout, "\n"),
+		"exit_code": c.exitCode,
+	}
+}
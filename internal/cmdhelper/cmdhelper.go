@@ -0,0 +1,28 @@
+// Package cmdhelper holds helpers shared by the shell check and action for running commands
+// with github.com/go-cmd/cmd.
+package cmdhelper
+
+import (
+	"time"
+
+	"github.com/go-cmd/cmd"
+)
+
+// startPoll is how often Stop checks whether the command has started yet.
+const startPoll = 10 * time.Millisecond
+
+// Stop stops command, waiting for its process to start first if it has not yet. go-cmd ignores
+// a Stop made before the process is running and refuses any later one, so a command stopped too
+// early would otherwise run to completion. Stop returns straight away if the command never starts.
+func Stop(command *cmd.Cmd) {
+	ticker := time.NewTicker(startPoll)
+	defer ticker.Stop()
+	for command.Status().PID == 0 {
+		select {
+		case <-command.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	command.Stop()
+}
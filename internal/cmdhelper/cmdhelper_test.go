@@ -0,0 +1,33 @@
+package cmdhelper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-cmd/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopBeforeStarted(t *testing.T) {
+	for range 20 {
+		command := cmd.NewCmd("sleep", "5")
+		statusChan := command.Start()
+		Stop(command)
+
+		select {
+		case status := <-statusChan:
+			require.False(t, status.Complete)
+		case <-time.After(2 * time.Second):
+			t.Fatal("command kept running after Stop")
+		}
+	}
+}
+
+func TestStopNeverStarted(t *testing.T) {
+	command := cmd.NewCmd("/nonexistent/duck-test-command")
+	statusChan := command.Start()
+	Stop(command)
+
+	status := <-statusChan
+	require.Error(t, status.Error)
+}
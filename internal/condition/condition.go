@@ -0,0 +1,119 @@
+// Package condition evaluates the CEL expressions actions use in their when field to decide
+// whether they run, such as vars.env == "prod" or checks[0].status == "passed".
+package condition
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+)
+
+var (
+	envOnce  sync.Once
+	celEnv   *cel.Env
+	envErr   error
+	programs sync.Map // compiled programs by expression
+)
+
+// environment returns the CEL environment expressions are compiled in. It declares:
+//
+//	vars    map of duckfile variables and values registered so far in the run
+//	env     map of the environment variables of the duck process
+//	host    map of facts about the host: hostname, os, arch and num_cpu
+//	checks  list of the results of the checks of the target, each with index, key, type and status
+//	actions list of the results of the actions of the target that ran before this one, each
+//	        also carrying changed
+func environment() (*cel.Env, error) {
+	envOnce.Do(func() {
+		celEnv, envErr = cel.NewEnv(
+			cel.Variable("vars", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("env", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("host", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("checks", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+			cel.Variable("actions", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		)
+	})
+	return celEnv, envErr
+}
+
+// Compile parses and type checks expr, returning an error if it is invalid or does not
+// evaluate to a bool. Programs are cached so an expression is only compiled once.
+func Compile(expr string) (cel.Program, error) {
+	if prg, ok := programs.Load(expr); ok {
+		return prg.(cel.Program), nil
+	}
+
+	env, err := environment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression %q must evaluate to a bool, not %s", expr, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+	programs.Store(expr, prg)
+	return prg, nil
+}
+
+// Evaluate evaluates expr against data, which holds the variables declared by the environment.
+// Variables missing from data are empty.
+func Evaluate(expr string, data map[string]interface{}) (bool, error) {
+	prg, err := Compile(expr)
+	if err != nil {
+		return false, err
+	}
+
+	activation := map[string]interface{}{
+		"vars":    map[string]interface{}{},
+		"env":     map[string]string{},
+		"host":    map[string]interface{}{},
+		"checks":  []interface{}{},
+		"actions": []interface{}{},
+	}
+	for name, value := range data {
+		activation[name] = value
+	}
+
+	out, _, err := prg.Eval(activation)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate %q: %w", expr, err)
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q evaluated to %v, not a bool", expr, out.Value())
+	}
+	return result, nil
+}
+
+// Environ returns the environment variables of the process as a map.
+func Environ() map[string]string {
+	environ := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			environ[name] = value
+		}
+	}
+	return environ
+}
+
+// Facts returns facts about the host duck runs on.
+func Facts() map[string]interface{} {
+	hostname, _ := os.Hostname()
+	return map[string]interface{}{
+		"hostname": hostname,
+		"os":       runtime.GOOS,
+		"arch":     runtime.GOARCH,
+		"num_cpu":  runtime.NumCPU(),
+	}
+}
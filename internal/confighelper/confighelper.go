@@ -5,11 +5,14 @@ package confighelper
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/knadh/koanf/v2"
 )
 
@@ -88,9 +91,18 @@ func (cl *ConfigHelper) Load(config interface{}, konfig *koanf.Koanf, path strin
 		return fmt.Errorf("error setting defaults: %w", err)
 	}
 
-	// Unmarshal configuration from koanf into the config struct
+	// Unmarshal configuration from koanf into the config struct, with the hooks koanf uses by
+	// default behind one refusing durations without a unit
 	if err := konfig.UnmarshalWithConf(path, config, koanf.UnmarshalConf{
 		Tag: marshalTag,
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				unitlessDurationHookFunc(),
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.TextUnmarshallerHookFunc()),
+			Result:           config,
+			WeaklyTypedInput: true,
+		},
 	}); err != nil {
 		return fmt.Errorf("error unmarshalling config: %w", err)
 	}
@@ -105,9 +117,59 @@ func (cl *ConfigHelper) Load(config interface{}, konfig *koanf.Koanf, path strin
 	return nil
 }
 
+// unitlessDurationHookFunc refuses a bare number such as 30 for a time.Duration, which would
+// otherwise be read as nanoseconds. Durations are written with a unit, such as "30s" or "1m30s";
+// 0 is accepted as it means the same in any unit.
+func unitlessDurationHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if t != reflect.TypeOf(time.Duration(0)) || f == t {
+			return data, nil
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if !reflect.ValueOf(data).IsZero() {
+				return nil, fmt.Errorf("duration %v has no unit, write it such as \"%vs\"", data, data)
+			}
+		}
+		return data, nil
+	}
+}
+
 // RegisterValidation registers a custom validation function
 func (cl *ConfigHelper) RegisterValidation(tag string, fn validator.Func) error {
 	cl.mu.Lock()
 	defer cl.mu.Unlock()
 	return cl.validate.RegisterValidation(tag, fn)
 }
+
+// Dump is the reverse of Load, it flattens a hydrated config struct back into a map keyed
+// by the marshalTag struct tags so the effective configuration (defaults included) can be shown.
+func (cl *ConfigHelper) Dump(config interface{}, marshalTag string) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: marshalTag,
+		Result:  &out,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating decoder: %w", err)
+	}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("error dumping config: %w", err)
+	}
+	formatDurations(out)
+	return out, nil
+}
+
+// formatDurations rewrites durations in a dumped config to the same "1m30s" form they are read in.
+func formatDurations(m map[string]interface{}) {
+	for key, value := range m {
+		switch v := value.(type) {
+		case time.Duration:
+			m[key] = v.String()
+		case map[string]interface{}:
+			formatDurations(v)
+		}
+	}
+}
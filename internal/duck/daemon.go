@@ -0,0 +1,439 @@
+package duck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/mad-weaver/duck/internal/target"
+)
+
+// job is a set of selected targets the daemon runs together on one schedule, along with
+// everything they depend on. Every target with a schedule of its own gets a job, the targets
+// without one share a job run every --daemon-interval seconds.
+type job struct {
+	name     string // the targets joined with ", ", which identifies the job across reloads
+	roots    []string
+	schedule *target.Schedule
+	pause    bool          // count the next run from the end of the last one, as --daemon-interval is a pause between runs
+	d        *Duck         // duck the targets were compiled by
+	targets  []string      // the targets and their dependencies, none of which may be in another run
+	next     time.Time     // when the job is due
+	last     time.Time     // when the last run started, zero until the job first runs
+	status   report.Status // outcome of the last run
+	running  bool
+}
+
+// reload is the outcome of recompiling the duckfiles after a change.
+type reload struct {
+	d     *Duck
+	jobs  []*job
+	watch *watchSet // what the Watcher watches from now on, nil without a Watcher
+	err   error
+}
+
+// Scheduler runs the selected targets of a duck in daemon mode, each on the schedule it defines
+// or, together with the other targets without one, every --daemon-interval seconds. Every run is
+// a run of its own: the targets and their dependencies are reset, run with a fresh variable store
+// and reported separately. Runs that share a target are serialized, a job that comes due while
+// another run uses one of its targets waits for that run to finish, and due jobs start in the
+// order they came due. When the Watcher reports a change the duckfiles are compiled again in the
+// background and the new targets are swapped in between runs, only if they compiled; runs in
+// flight finish with the targets they started with.
+type Scheduler struct {
+	Iterations int                     // stop after this many runs, 0 means no limit
+	Timeout    time.Duration           // stop starting runs after this long, 0 means no limit
+	OnRun      func(*report.RunReport) // called after every run, such as to write the report
+	OnSchedule func()                  // called whenever the next run times change, such as to write WriteStatus
+	Watcher    *Watcher                // reports duckfile changes to reload on, nil to never reload
+
+	d       *Duck
+	jobs    []*job
+	mu      sync.Mutex    // guards jobs, which WriteStatus reads while runs are in flight
+	reloads chan struct{} // reload requested with Reload
+	runNow  chan struct{} // immediate run requested with RunNow
+}
+
+// NewScheduler compiles the targets of d and prepares the jobs of the selected targets. Errors
+// wrap duckerr.ErrConfigInvalid, or duckerr.ErrInterrupted if ctx was cancelled while compiling.
+func NewScheduler(ctx context.Context, d *Duck) (*Scheduler, error) {
+	jobs, err := d.jobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{d: d, jobs: jobs, reloads: make(chan struct{}, 1), runNow: make(chan struct{}, 1)}, nil
+}
+
+// Reload asks Run to reload the duckfiles as if the Watcher had reported a change. It does not
+// wait for the reload, requests made while one is pending are folded into it.
+func (s *Scheduler) Reload() {
+	select {
+	case s.reloads <- struct{}{}:
+	default:
+	}
+}
+
+// RunNow asks Run to start every job that is not running straight away, regardless of its
+// schedule. The next run of each job is then counted from this one.
+func (s *Scheduler) RunNow() {
+	select {
+	case s.runNow <- struct{}{}:
+	default:
+	}
+}
+
+// jobs compiles the targets of d and groups the selected targets into jobs as NewScheduler
+// describes.
+func (d *Duck) jobs(ctx context.Context) ([]*job, error) {
+	if err := d.CompileTargets(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %w", duckerr.ErrInterrupted, err)
+		}
+		return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+	roots, err := d.SelectTargets()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+
+	var jobs []*job
+	var unscheduled []string
+	for _, name := range roots {
+		if d.Targets[name].Schedule == nil {
+			unscheduled = append(unscheduled, name)
+			continue
+		}
+		j, err := d.newJob([]string{name}, d.Targets[name].Schedule, false)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	if len(unscheduled) > 0 {
+		fallback := &target.Schedule{Interval: time.Duration(d.Config.DaemonInterval) * time.Second}
+		j, err := d.newJob(unscheduled, fallback, true)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func (d *Duck) newJob(roots []string, schedule *target.Schedule, pause bool) (*job, error) {
+	g, err := d.buildGraph(roots, make(map[string]struct{}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+	return &job{
+		name:     strings.Join(roots, ", "),
+		roots:    roots,
+		schedule: schedule,
+		pause:    pause,
+		d:        d,
+		targets:  g.order,
+	}, nil
+}
+
+// Run starts every job when it comes due until ctx is cancelled, Timeout passes, Iterations runs
+// have finished, a run returns an error or duck starts shutting down (see WithShutdown), then waits
+// for the runs in flight and returns the errors they returned. Cancelling ctx interrupts the runs
+// in flight as it does for Duck.Run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	type outcome struct {
+		job *job
+		rep *report.RunReport
+		err error
+	}
+
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timeout = time.After(s.Timeout)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		next, err := j.schedule.Next(now, time.Time{})
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: target %s: %w", duckerr.ErrConfigInvalid, j.name, err)
+		}
+		j.next = next
+		slog.Info("Scheduled first run", "target", j.name, "schedule", j.schedule.String(), "at", j.next)
+	}
+	s.mu.Unlock()
+	if s.OnSchedule != nil {
+		s.OnSchedule()
+	}
+
+	var changes <-chan struct{}
+	if s.Watcher != nil {
+		changes = s.Watcher.Changes()
+		go s.Watcher.Run(ctx)
+	}
+	reloaded := make(chan reload, 1)
+	reloading := false
+
+	done := ctx.Done()
+	shutdown := shutdownFromContext(ctx)
+	outcomes := make(chan outcome)
+	busy := make(map[string]bool)
+	running, runs := 0, 0
+	stopping := false
+	var errs []error
+
+	for {
+		var wake time.Time
+		s.mu.Lock()
+		// the job that has been due the longest goes first, so a job that is always due cannot
+		// keep another that shares a target with it from ever running.
+		for _, j := range slices.SortedStableFunc(slices.Values(s.jobs), func(a, b *job) int { return a.next.Compare(b.next) }) {
+			if stopping || j.running {
+				continue
+			}
+			if time.Now().Before(j.next) {
+				if wake.IsZero() || j.next.Before(wake) {
+					wake = j.next
+				}
+				continue
+			}
+			if s.blocked(j, busy) {
+				slog.Debug("Scheduled target shares a target with a run in flight, waiting", "target", j.name)
+				continue
+			}
+
+			for _, name := range j.targets {
+				busy[name] = true
+				j.d.Targets[name].Reset()
+			}
+			j.running = true
+			j.last = time.Now()
+			running++
+			slog.Info("Running scheduled target", "target", j.name)
+			go func(j *job) {
+				rep := &report.RunReport{
+					Targets: j.roots,
+					Check:   j.d.Config.Check,
+					Started: j.last,
+					Results: []*report.TargetResult{},
+				}
+				results, err := j.d.Execute(ctx, j.roots)
+				if results != nil {
+					rep.Results = results
+				}
+				rep.Finish(err)
+				outcomes <- outcome{job: j, rep: rep, err: err}
+			}(j)
+		}
+		s.mu.Unlock()
+
+		if stopping && running == 0 {
+			return errors.Join(errs...)
+		}
+
+		var timer <-chan time.Time
+		if !wake.IsZero() {
+			timer = time.After(time.Until(wake))
+		}
+		changed, requested := changes, s.reloads
+		if reloading || stopping {
+			changed, requested = nil, nil
+		}
+		select {
+		case <-done:
+			slog.Info("Received interrupt signal, terminating")
+			stopping = true
+			done = nil
+		case <-shutdown:
+			slog.Info("Shutting down, no further runs will be started", "running", running)
+			stopping = true
+			shutdown = nil
+		case <-timeout:
+			slog.Info("Daemon timeout reached, terminating")
+			stopping = true
+			timeout = nil
+		case <-timer:
+		case <-changed:
+			slog.Info("Duckfiles changed, reloading")
+			reloading = true
+			go s.reload(ctx, s.d.Config, reloaded)
+		case <-requested:
+			slog.Info("Reload requested, reloading")
+			reloading = true
+			go s.reload(ctx, s.d.Config, reloaded)
+		case <-s.runNow:
+			if stopping {
+				continue
+			}
+			slog.Info("Immediate run requested, running every scheduled target now")
+			s.mu.Lock()
+			for _, j := range s.jobs {
+				if !j.running {
+					j.next = time.Now()
+				}
+			}
+			s.mu.Unlock()
+		case r := <-reloaded:
+			reloading = false
+			if stopping {
+				continue
+			}
+			if r.err != nil {
+				slog.Error("Failed to reload duckfiles, keeping the current targets", "error", r.err)
+				continue
+			}
+			if r.watch != nil {
+				if err := s.Watcher.replace(r.watch); err != nil {
+					slog.Warn("Failed to watch the reloaded duckfiles", "error", err)
+				}
+			}
+			s.swap(r.d, r.jobs)
+			if s.OnSchedule != nil {
+				s.OnSchedule()
+			}
+		case o := <-outcomes:
+			running--
+			runs++
+			for _, name := range o.job.targets {
+				delete(busy, name)
+			}
+
+			var next time.Time
+			var err error
+			s.mu.Lock()
+			// the job may have been replaced by a reload while it ran
+			if j := s.job(o.job.name); j != nil {
+				j.running = false
+				j.status = o.rep.Status
+				last := j.last
+				if j.pause {
+					last = time.Now()
+				}
+				if next, err = j.schedule.Next(time.Now(), last); err != nil {
+					err = fmt.Errorf("%w: target %s: %w", duckerr.ErrConfigInvalid, j.name, err)
+				} else {
+					j.next = next
+				}
+			}
+			s.mu.Unlock()
+			if s.OnRun != nil {
+				s.OnRun(o.rep)
+			}
+			if s.OnSchedule != nil {
+				s.OnSchedule()
+			}
+
+			if err == nil {
+				err = o.err
+			}
+			if err != nil {
+				slog.Debug("Scheduled run failed, no further runs will be started", "target", o.job.name, "error", err)
+				errs = append(errs, err)
+				stopping = true
+			} else if !next.IsZero() && !stopping {
+				slog.Info("Scheduled next run", "target", o.job.name, "at", next)
+			}
+			if s.Iterations > 0 && runs >= s.Iterations && !stopping {
+				slog.Info("Reached maximum number of iterations, terminating")
+				stopping = true
+			}
+		}
+	}
+}
+
+// reload compiles the duckfiles again with the configuration cfg of the current duck and sends
+// the outcome to reloaded. The new duckfiles are fingerprinted for the Watcher here too, so slow
+// remote duckfiles hold up neither the start nor the end of runs.
+func (s *Scheduler) reload(ctx context.Context, cfg Config, reloaded chan<- reload) {
+	d := newDuck(cfg)
+	jobs, err := d.jobs(ctx)
+	var watch *watchSet
+	if err == nil && s.Watcher != nil {
+		watch = newWatchSet(ctx, d)
+	}
+	reloaded <- reload{d: d, jobs: jobs, watch: watch, err: err}
+}
+
+// swap replaces the jobs with those of a reloaded duck. A job that kept its name and schedule
+// keeps when it last ran, how that went and when it runs next; a job still running keeps running
+// with the targets it started with and is scheduled with its new definition once it finishes.
+func (s *Scheduler) swap(d *Duck, jobs []*job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var swapped []*job
+	for _, j := range jobs {
+		if old := s.job(j.name); old != nil {
+			j.last, j.status, j.running = old.last, old.status, old.running
+			if old.schedule.String() == j.schedule.String() {
+				j.next = old.next
+			}
+		}
+		if j.next.IsZero() && !j.running {
+			next, err := j.schedule.Next(now, j.last)
+			if err != nil {
+				slog.Error("Failed to schedule reloaded target, leaving it out", "target", j.name, "error", err)
+				continue
+			}
+			j.next = next
+		}
+		swapped = append(swapped, j)
+	}
+	s.d, s.jobs = d, swapped
+	slog.Info("Reloaded duckfiles", "jobs", len(s.jobs))
+}
+
+// job returns the current job with the given name, nil if there is none. s.mu must be held.
+func (s *Scheduler) job(name string) *job {
+	for _, j := range s.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// blocked reports whether any target of j is used by a run in flight.
+func (s *Scheduler) blocked(j *job, busy map[string]bool) bool {
+	for _, name := range j.targets {
+		if busy[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteStatus writes a table of the scheduled targets with their schedule, when they last ran
+// and how that went, and when they run next.
+func (s *Scheduler) WriteStatus(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSCHEDULE\tLAST RUN\tSTATUS\tNEXT RUN")
+	for _, j := range s.jobs {
+		last, status, next := "-", "-", "running"
+		if !j.last.IsZero() {
+			last = j.last.Format(time.RFC3339)
+		}
+		if j.status != "" {
+			status = string(j.status)
+		}
+		if !j.running {
+			next = j.next.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", j.name, j.schedule, last, status, next)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,118 @@
+package duck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestScheduler writes content to a duckfile and returns a scheduler for the given targets.
+func newTestScheduler(t *testing.T, content string, targets ...string) *Scheduler {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.duck")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	d := newTestDuck(path)
+	d.Config.Target = targets
+	s, err := NewScheduler(context.Background(), d)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSchedulerSerializesJobsSharingATarget(t *testing.T) {
+	s := newTestScheduler(t, `
+shared:
+  actions: [{type: shell, params: {command: sleep, args: ["0.2"]}}]
+a:
+  dependencies: [shared]
+  schedule: {interval: 50ms}
+  actions: [{type: dummy}]
+b:
+  dependencies: [shared]
+  schedule: {interval: 50ms}
+  actions: [{type: dummy}]
+`, "a", "b")
+
+	var reports []*report.RunReport
+	s.Iterations = 4
+	s.OnRun = func(rep *report.RunReport) { reports = append(reports, rep) }
+	require.NoError(t, s.Run(context.Background()))
+	require.Len(t, reports, 4)
+
+	ran := make(map[string]bool)
+	for i, a := range reports {
+		ran[a.Targets[0]] = true
+		aEnd := a.Started.Add(time.Duration(a.Duration * float64(time.Second)))
+		for _, b := range reports[i+1:] {
+			bEnd := b.Started.Add(time.Duration(b.Duration * float64(time.Second)))
+			require.False(t, a.Started.Before(bEnd) && b.Started.Before(aEnd),
+				"runs of %v and %v overlap", a.Targets, b.Targets)
+		}
+	}
+	require.True(t, ran["a"] && ran["b"], "both jobs should have run")
+}
+
+func TestSchedulerSwapKeepsJobState(t *testing.T) {
+	s := newTestScheduler(t, `
+a:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+b:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+c:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+`, "a", "b", "c")
+
+	last := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	next := last.Add(time.Hour)
+	for _, j := range s.jobs {
+		j.last, j.next, j.status = last, next, report.StatusPassed
+	}
+	s.job("c").running, s.job("c").next = true, time.Time{}
+
+	reloaded := newTestScheduler(t, `
+a:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+b:
+  schedule: {interval: 2h}
+  actions: [{type: dummy}]
+c:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+d:
+  schedule: {interval: 1h}
+  actions: [{type: dummy}]
+`, "a", "b", "c", "d")
+	s.swap(reloaded.d, reloaded.jobs)
+	require.Same(t, reloaded.d, s.d)
+	require.Len(t, s.jobs, 4)
+
+	// same schedule: keeps when it last ran, how that went and when it runs next
+	a := s.job("a")
+	require.Equal(t, last, a.last)
+	require.Equal(t, next, a.next)
+	require.Equal(t, report.StatusPassed, a.status)
+
+	// new schedule: keeps its history, next run counted from the last with the new interval
+	b := s.job("b")
+	require.Equal(t, last, b.last)
+	require.Equal(t, last.Add(2*time.Hour), b.next)
+
+	// still running: scheduled once the run finishes
+	c := s.job("c")
+	require.True(t, c.running)
+	require.True(t, c.next.IsZero())
+
+	// new job: due straight away
+	d := s.job("d")
+	require.True(t, d.last.IsZero())
+	require.False(t, d.next.After(time.Now()))
+}
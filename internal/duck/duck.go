@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"time"
 
 	"github.com/knadh/koanf/v2"
 
+	"github.com/mad-weaver/duck/internal/actions"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
 	"github.com/mad-weaver/duck/internal/target"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 const (
@@ -20,18 +25,36 @@ type Duck struct {
 	Config    Config
 	Duckfiles map[string]url.URL
 	Targets   map[string]*target.Target
+	Vars      map[string]interface{} // duckfile variables after overrides, set by CompileTargets
+
+	documents    []document
+	definitions  map[string]definition // raw target definitions from every duckfile, by name
+	registered   []string              // names steps register their outcome under, resolved at run time
+	profileFound bool                  // whether any loaded duckfile defines Config.Profile
+	disabled     map[string]string     // targets left out with enabled: false, mapped to their duckfile
+	abstract     map[string]string     // targets only meant to be extended, mapped to their duckfile
 }
 
 type Config struct {
-	Files            []string `mapstructure:"file" validate:"required"`
-	ListTargets      bool     `mapstructure:"list-targets" default:"false"`
-	Target           string   `mapstructure:"target" default:"default"`
-	Daemon           bool     `mapstructure:"daemon" default:"false"`
-	DaemonInterval   int      `mapstructure:"daemon-interval" default:"60"`
-	DaemonIterations int      `mapstructure:"daemon-iterations" default:"0"`
-	DaemonTimeout    int      `mapstructure:"daemon-timeout" default:"0"`
-	LogLevel         string   `mapstructure:"loglevel" default:"info"`
-	LogFormat        string   `mapstructure:"logformat" default:"text"`
+	Files              []string `mapstructure:"file" validate:"required"`
+	ListTargets        bool     `mapstructure:"list-targets" default:"false"`
+	Target             []string `mapstructure:"target"` // target names or globs, see SelectTargets
+	Tags               []string `mapstructure:"tags"`
+	SkipTags           []string `mapstructure:"skip-tags"`
+	Daemon             bool     `mapstructure:"daemon" default:"false"`
+	DaemonInterval     int      `mapstructure:"daemon-interval" default:"60"`
+	DaemonIterations   int      `mapstructure:"daemon-iterations" default:"0"`
+	DaemonTimeout      int      `mapstructure:"daemon-timeout" default:"0"`
+	DaemonStatus       string   `mapstructure:"daemon-status"` // file the scheduler status is written to, - for stdout
+	DaemonPollInterval int      `mapstructure:"daemon-poll-interval" default:"60"`
+	MaxParallel        int      `mapstructure:"max-parallel" default:"1" validate:"min=0"`
+	Report             string   `mapstructure:"report"`
+	ReportFormat       string   `mapstructure:"report-format" default:"json" validate:"oneof=json junit tap"`
+	Set                []string `mapstructure:"set"`                   // variable overrides as key=value
+	Profile            string   `mapstructure:"profile"`               // name of the _meta.profiles entry to apply
+	Check              bool     `mapstructure:"check" default:"false"` // preview actions with DryRun instead of running them
+	LogLevel           string   `mapstructure:"loglevel" default:"info"`
+	LogFormat          string   `mapstructure:"logformat" default:"text"`
 }
 
 // NewDuck creates a new Duck object from a koanf object.
@@ -41,31 +64,109 @@ func NewDuck(k *koanf.Koanf) (*Duck, error) {
 	cfg := &Config{}
 	cfghelper := confighelper.GetConfigHelper()
 	if err := cfghelper.Load(cfg, k, "", "mapstructure"); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
 	}
 
+	return newDuck(*cfg), nil
+}
+
+// newDuck creates an uncompiled duck object from an already hydrated configuration.
+func newDuck(cfg Config) *Duck {
 	return &Duck{
-		Config:    *cfg,
+		Config:    cfg,
 		Duckfiles: make(map[string]url.URL),
 		Targets:   make(map[string]*target.Target),
-	}, nil
+		disabled:  make(map[string]string),
+		abstract:  make(map[string]string),
+	}
 }
 
-// Run will compile the targets and run the target specified by the target name.
-// It is the main execution function for duck.
-func (d *Duck) Run(ctx context.Context) error {
+// Run will compile the targets and run the targets selected by the target patterns and tags.
+// It is the main execution function for duck. It returns a report of everything that ran,
+// which is nil when only listing targets or when nothing was attempted. Errors wrap one of
+// the duckerr sentinels when the cause is known: compile and selection failures wrap
+// duckerr.ErrConfigInvalid and a cancelled context wraps duckerr.ErrInterrupted.
+func (d *Duck) Run(ctx context.Context) (*report.RunReport, error) {
 	if err := ctx.Err(); err != nil {
-		return fmt.Errorf("context cancelled before execution: %w", err)
+		return nil, fmt.Errorf("%w: context cancelled before execution: %w", duckerr.ErrInterrupted, err)
+	}
+
+	rep := &report.RunReport{
+		Targets: d.Config.Target,
+		Check:   d.Config.Check,
+		Started: time.Now(),
+		Results: []*report.TargetResult{},
 	}
 
 	slog.Debug("Compiling targets", "duck", d)
 	if err := d.CompileTargets(ctx); err != nil {
-		return err
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", duckerr.ErrInterrupted, err)
+		} else {
+			err = fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+		}
+		rep.Finish(err)
+		return rep, err
 	}
 
 	if d.Config.ListTargets {
-		return d.ListTargets(ctx)
+		return nil, d.ListTargets(ctx)
 	}
 
-	return d.RunTarget(ctx, d.Config.Target, make(map[string]struct{}))
+	roots, err := d.SelectTargets()
+	if err != nil {
+		err = fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+		rep.Finish(err)
+		return rep, err
+	}
+	rep.Targets = roots
+
+	results, err := d.Execute(ctx, roots)
+	if results != nil {
+		rep.Results = results
+	}
+	rep.Finish(err)
+	return rep, err
+}
+
+// Execute runs the given targets and everything they depend on, sharing a fresh variable store
+// seeded with the duckfile variables between them, and returns the result of every target run.
+// Assumes CompileTargets was called and the targets were not already cleared.
+func (d *Duck) Execute(ctx context.Context, roots []string) ([]*report.TargetResult, error) {
+	// values registered by steps are only shared between the targets of this run
+	store := vars.NewStore()
+	for name, value := range d.Vars {
+		store.Set(name, value)
+	}
+	ctx = vars.WithStore(ctx, store)
+	if d.Config.Check {
+		slog.Info("Check mode, actions are previewed and not executed")
+		ctx = actions.WithCheckMode(ctx)
+	}
+	return d.RunTargets(ctx, roots, make(map[string]struct{}))
+}
+
+type shutdownKey struct{}
+
+// WithShutdown returns a copy of ctx carrying shutdown, a channel closed when duck is asked to shut
+// down gracefully. Once it is closed no new target or scheduled run is started, while those already
+// running carry on until they finish or ctx itself is cancelled.
+func WithShutdown(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// shutdownFromContext returns the channel attached to ctx by WithShutdown, nil if none.
+func shutdownFromContext(ctx context.Context) <-chan struct{} {
+	shutdown, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return shutdown
+}
+
+// shuttingDown reports whether the channel attached to ctx by WithShutdown is closed.
+func shuttingDown(ctx context.Context) bool {
+	select {
+	case <-shutdownFromContext(ctx):
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,123 @@
+package duck
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// definition is the raw definition of a target as found in its duckfile.
+type definition struct {
+	konfig *koanf.Koanf
+	source string // url of the duckfile the target was defined in
+}
+
+// listKeys are the target keys whose lists are combined according to the merge strategy when a
+// target extends another.
+var listKeys = []string{"checks", "actions", "on_failure", "always", "handlers", "dependencies", "tags"}
+
+// uninherited are the target keys that describe the definition itself and are never copied
+// from the target being extended.
+var uninherited = []string{"abstract", "extends", "merge", "enabled"}
+
+// extend resolves the extends key of the target definition k, returning k deep-merged on top of
+// the fully resolved definition of the target it names. Maps such as config are merged key by
+// key with k winning. Lists of checks, actions, on_failure, always, dependencies and tags are
+// appended to the parent's with merge: append, the default, or replace the parent's with
+// merge: replace. chain holds the targets already being resolved so loops are reported.
+func (d *Duck) extend(name string, k *koanf.Koanf, chain []string) (*koanf.Koanf, error) {
+	parent := k.String("extends")
+	if parent == "" {
+		return k, nil
+	}
+
+	chain = append(chain, name)
+	if slices.Contains(chain, parent) {
+		return nil, fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), parent)
+	}
+
+	strategy := k.String("merge")
+	if strategy == "" {
+		strategy = "append"
+	}
+	if strategy != "append" && strategy != "replace" {
+		return nil, fmt.Errorf("invalid merge strategy %s for target %s, expected append or replace", strategy, name)
+	}
+
+	def, exists := d.definitions[parent]
+	if !exists {
+		return nil, fmt.Errorf("target %s extends missing target %s", name, parent)
+	}
+	base, err := d.extend(parent, def.konfig, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := base.Raw()
+	for _, key := range uninherited {
+		delete(merged, key)
+	}
+	for key, value := range k.Raw() {
+		inherited, ok := merged[key]
+		switch {
+		case !ok:
+			merged[key] = value
+		case slices.Contains(listKeys, key) && strategy == "append":
+			merged[key] = append(asList(inherited), asList(value)...)
+		default:
+			merged[key] = mergeValues(inherited, value)
+		}
+	}
+
+	out := koanf.New(k.Delim())
+	for key, value := range merged {
+		if err := out.Set(key, value); err != nil {
+			return nil, fmt.Errorf("failed to extend target %s: %w", name, err)
+		}
+	}
+	return out, nil
+}
+
+// asList returns the elements of a list value whatever its element type, as lists set in code
+// such as []string are not the []interface{} read from a duckfile. Anything else is a list of one.
+func asList(value interface{}) []interface{} {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list
+}
+
+// mergeValues deep-merges override on top of base when both are maps, otherwise override wins.
+func mergeValues(base interface{}, override interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	overrideMap, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+
+	merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		if existing, ok := merged[key]; ok {
+			value = mergeValues(existing, value)
+		}
+		merged[key] = value
+	}
+	return merged
+}
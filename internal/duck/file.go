@@ -16,6 +16,7 @@ import (
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/vars"
 	"gocloud.dev/blob"
 
 	_ "gocloud.dev/blob/azureblob"
@@ -25,6 +26,8 @@ import (
 
 // CompileTargets will compile the targets from the duckfiles specified when the
 // constructor was called for duck. accepts a context, only affects internal state of duck object.
+// Every duckfile and its dependencies are loaded first so the variables they declare can be
+// merged and overridden before any target is built.
 func (d *Duck) CompileTargets(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled before execution: %w", err)
@@ -42,23 +45,44 @@ func (d *Duck) CompileTargets(ctx context.Context) error {
 			}
 		}
 	}
-	return nil
+
+	if d.Config.Profile != "" && !d.profileFound {
+		return fmt.Errorf("profile %s is not defined in any duckfile", d.Config.Profile)
+	}
+
+	if err := d.resolveVars(); err != nil {
+		return err
+	}
+
+	if err := d.compileDocuments(ctx); err != nil {
+		return err
+	}
+
+	return d.ValidateTargets()
 }
 
-// LoadDuckfile will load a duckfile into the duck object.
+// LoadDuckfile will load a duckfile into the duck object, queueing its targets to be compiled.
 // accepts a context, a duckfile url, and a recurse bool. recurse is used to
 // signal if the duckfile is loaded in a manner that will also load any dependencies
 // found in its _meta section.
 func (d *Duck) LoadDuckfile(ctx context.Context, duckfile url.URL, recurse bool) error {
+	return d.loadDuckfile(ctx, duckfile, recurse, "")
+}
+
+// loadDuckfile loads a duckfile as LoadDuckfile does, importing its targets into namespace.
+// Dependencies imported with an alias go into that namespace below namespace, the others
+// share namespace. A duckfile is loaded once per namespace, so d.Duckfiles is keyed by url,
+// qualified with the namespace for duckfiles loaded into one.
+func (d *Duck) loadDuckfile(ctx context.Context, duckfile url.URL, recurse bool, namespace string) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
-	if _, exists := d.Duckfiles[duckfile.String()]; exists {
+	if _, exists := d.Duckfiles[qualify(namespace, duckfile.String())]; exists {
 		return nil
 	}
 
-	d.Duckfiles[duckfile.String()] = duckfile
+	d.Duckfiles[qualify(namespace, duckfile.String())] = duckfile
 
 	var k *koanf.Koanf
 	var err error
@@ -74,41 +98,106 @@ func (d *Duck) LoadDuckfile(ctx context.Context, duckfile url.URL, recurse bool)
 		if err != nil {
 			return err
 		}
-	case "s3", "gs", "azblob":
+	default:
+		if !isBlobScheme(duckfile.Scheme) {
+			return fmt.Errorf("unsupported scheme: %s", duckfile.Scheme)
+		}
 		k, err = loadCloudURL(ctx, duckfile)
 		if err != nil {
 			return err
 		}
-	default:
-		return fmt.Errorf("unsupported scheme: %s", duckfile.Scheme)
-	}
-
-	// Get all top level keys from the koanf object. does not load _meta key as that's reserved.
-	for _, key := range k.MapKeys("") {
-		// don't load _meta key, if recurse is true, load up the list of files inside it and start baking targets.
-		if key == "_meta" {
-			if recurse {
-				if deps := k.Strings("_meta" + ModifiedColon + "dependencies"); len(deps) > 0 {
-					for _, dep := range deps {
-						depURLs, err := GetDuckfiles(ctx, dep)
-						if err != nil {
-							return fmt.Errorf("failed to extract duckfile urls for dependency %s: %w", dep, err)
-						}
-						for _, depURL := range depURLs {
-							if err := d.LoadDuckfile(ctx, depURL, false); err != nil {
-								return fmt.Errorf("failed to load dependency duckfile %s: %w", depURL.String(), err)
-							}
-						}
-					}
+	}
+
+	found, err := d.applyProfile(k)
+	if err != nil {
+		return fmt.Errorf("failed to apply profile to %s: %w", duckfile.String(), err)
+	}
+	d.profileFound = d.profileFound || found
+
+	d.documents = append(d.documents, document{url: duckfile, konfig: k, dependency: !recurse, namespace: namespace})
+
+	// if recurse is true, load up the list of files inside the _meta key and queue them as well.
+	if recurse {
+		deps, err := dependencies(k)
+		if err != nil {
+			return fmt.Errorf("invalid dependencies in %s: %w", duckfile.String(), err)
+		}
+		for _, dep := range deps {
+			location, err := resolveLocation(duckfile, dep.location)
+			if err != nil {
+				return fmt.Errorf("failed to resolve dependency %s: %w", dep.location, err)
+			}
+			depURLs, err := GetDuckfiles(ctx, location)
+			if err != nil {
+				return fmt.Errorf("failed to extract duckfile urls for dependency %s: %w", dep.location, err)
+			}
+			depNamespace := namespace
+			if dep.as != "" {
+				depNamespace = qualify(namespace, dep.as)
+			}
+			for _, depURL := range depURLs {
+				if err := d.loadDuckfile(ctx, depURL, false, depNamespace); err != nil {
+					return fmt.Errorf("failed to load dependency duckfile %s: %w", depURL.String(), err)
 				}
 			}
-			continue
 		}
+	}
+
+	return nil
+}
+
+// compileDocuments builds the targets of every loaded duckfile. The definitions of all targets
+// are collected first, under their names qualified with the namespace of their duckfile, so a
+// target can extend one from any duckfile, references to other targets can be resolved, and
+// names registered by a step anywhere are known before variables are substituted. Disabled and
+// abstract targets are recorded but not built.
+func (d *Duck) compileDocuments(ctx context.Context) error {
+	d.definitions = make(map[string]definition)
+	for _, doc := range d.documents {
+		for _, key := range doc.konfig.MapKeys("") {
+			if key == "_meta" {
+				continue
+			}
+			name := qualify(doc.namespace, key)
+			if existing, exists := d.definitions[name]; exists {
+				return fmt.Errorf("target %s already exists in %s", name, existing.source)
+			}
+			def := definition{konfig: doc.konfig.Cut(key), source: doc.url.String()}
+			d.definitions[name] = def
+			d.registered = append(d.registered, vars.Registered(def.konfig)...)
+		}
+	}
 
-		// Get the configuration for this target
-		targetConfig := k.Cut(key)
-		if err := d.appendTarget(ctx, key, targetConfig); err != nil {
-			return fmt.Errorf("failed to append target %s: %w", key, err)
+	for _, doc := range d.documents {
+		for _, key := range doc.konfig.MapKeys("") {
+			if key != "_meta" {
+				d.qualifyReferences(doc.namespace, d.definitions[qualify(doc.namespace, key)].konfig)
+			}
+		}
+	}
+
+	for _, doc := range d.documents {
+		// Get all top level keys from the koanf object. does not load _meta key as that's reserved.
+		for _, key := range doc.konfig.MapKeys("") {
+			if key == "_meta" {
+				continue
+			}
+			key = qualify(doc.namespace, key)
+			def := d.definitions[key]
+			if !enabled(def.konfig) {
+				slog.Debug("target disabled, skipping", "target", key, "profile", d.Config.Profile)
+				d.disabled[key] = def.source
+				continue
+			}
+			if def.konfig.Bool("abstract") {
+				slog.Debug("target is abstract, skipping", "target", key)
+				d.abstract[key] = def.source
+				continue
+			}
+
+			if err := d.appendTarget(ctx, key, def.source, def.konfig); err != nil {
+				return fmt.Errorf("failed to append target %s: %w", key, err)
+			}
 		}
 	}
 
@@ -193,6 +282,47 @@ func loadCloudURL(ctx context.Context, duckfile url.URL) (*koanf.Koanf, error) {
 	return k, nil
 }
 
+// resolveLocation returns where a dependency declared in the duckfile at parent lives. URLs are
+// returned as they are. Paths are resolved against the location of parent: its directory for a
+// local duckfile, absolute paths being left alone, or the URL of the duckfile for http(s) and
+// blob storage, where an absolute path is taken from the root of the same host or bucket. The
+// query of a blob URL is kept as it configures the bucket rather than the object. A remote
+// duckfile cannot name a local file, so it cannot read files off the machine duck runs on.
+func resolveLocation(parent url.URL, location string) (string, error) {
+	if parent.Scheme == "file" {
+		if strings.Contains(location, "://") || filepath.IsAbs(location) {
+			return location, nil
+		}
+		return filepath.Join(filepath.Dir(parent.Path), location), nil
+	}
+
+	if parent.Scheme != "http" && parent.Scheme != "https" && !isBlobScheme(parent.Scheme) {
+		return "", fmt.Errorf("cannot resolve %s relative to %s", location, parent.String())
+	}
+	if strings.HasPrefix(location, "file://") {
+		return "", fmt.Errorf("remote duckfile %s cannot depend on local file %s", parent.String(), location)
+	}
+	if strings.Contains(location, "://") {
+		return location, nil
+	}
+	ref, err := url.Parse(filepath.ToSlash(location))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse relative location %s: %w", location, err)
+	}
+	resolved := parent.ResolveReference(ref)
+	if parent.Scheme != "http" && parent.Scheme != "https" && ref.RawQuery == "" {
+		resolved.RawQuery = parent.RawQuery
+	}
+	return resolved.String(), nil
+}
+
+// isBlobScheme reports whether duckfiles under scheme are read from blob storage, which is the
+// case for every scheme gocloud has a driver registered for, such as s3, gs and azblob, except
+// file which is read from the local filesystem.
+func isBlobScheme(scheme string) bool {
+	return scheme != "file" && blob.DefaultURLMux().ValidBucketScheme(scheme)
+}
+
 // GetDuckfiles takes a string and returns a list of urls.
 func GetDuckfiles(ctx context.Context, floc string) ([]url.URL, error) {
 	if err := ctx.Err(); err != nil {
@@ -225,12 +355,13 @@ func GetDuckfiles(ctx context.Context, floc string) ([]url.URL, error) {
 	switch u.Scheme {
 	case "file":
 		return handleFileURL(ctx, u)
-	case "s3", "gs", "azblob":
-		return handleCloudURL(ctx, u, floc)
 	case "http", "https":
 		return handleHTTPURL(ctx, u, floc)
 	default:
-		return nil, fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+		if !isBlobScheme(u.Scheme) {
+			return nil, fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+		}
+		return handleCloudURL(ctx, u, floc)
 	}
 }
 
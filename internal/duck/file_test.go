@@ -0,0 +1,250 @@
+package duck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mad-weaver/duck/internal/target"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+)
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		parent   string
+		location string
+		want     string
+	}{
+		// file
+		{"file sibling", "file:///srv/duck/main.duck", "common.duck", "/srv/duck/common.duck"},
+		{"file subdirectory", "file:///srv/duck/main.duck", "lib/common.duck", "/srv/duck/lib/common.duck"},
+		{"file dot", "file:///srv/duck/main.duck", "./common.duck", "/srv/duck/common.duck"},
+		{"file parent directory", "file:///srv/duck/team/main.duck", "../common.duck", "/srv/duck/common.duck"},
+		{"file directory", "file:///srv/duck/main.duck", "lib/", "/srv/duck/lib"},
+		{"file absolute path", "file:///srv/duck/main.duck", "/etc/duck/common.duck", "/etc/duck/common.duck"},
+		{"file absolute url", "file:///srv/duck/main.duck", "https://example.com/common.duck", "https://example.com/common.duck"},
+
+		// http(s)
+		{"http sibling", "http://example.com/duck/main.duck", "common.duck", "http://example.com/duck/common.duck"},
+		{"https subdirectory", "https://example.com/duck/main.duck", "lib/common.duck", "https://example.com/duck/lib/common.duck"},
+		{"https parent directory", "https://example.com/duck/team/main.duck", "../common.duck", "https://example.com/duck/common.duck"},
+		{"https drops query", "https://example.com/duck/main.duck?token=abc", "common.duck", "https://example.com/duck/common.duck"},
+		{"https own query", "https://example.com/duck/main.duck", "common.duck?v=2", "https://example.com/duck/common.duck?v=2"},
+		{"https keeps port", "https://example.com:8443/duck/main.duck", "common.duck", "https://example.com:8443/duck/common.duck"},
+		{"https absolute path stays on host", "https://example.com/duck/main.duck", "/etc/duck/common.duck", "https://example.com/etc/duck/common.duck"},
+		{"https absolute url", "https://example.com/duck/main.duck", "s3://bucket/common.duck", "s3://bucket/common.duck"},
+
+		// blob
+		{"s3 sibling", "s3://bucket/team/main.duck", "common.duck", "s3://bucket/team/common.duck"},
+		{"s3 parent directory", "s3://bucket/team/main.duck", "../common.duck", "s3://bucket/common.duck"},
+		{"s3 keeps query", "s3://bucket/team/main.duck?region=eu-west-1", "common.duck", "s3://bucket/team/common.duck?region=eu-west-1"},
+		{"s3 prefix", "s3://bucket/team/main.duck", "lib/", "s3://bucket/team/lib/"},
+		{"s3 absolute path stays in bucket", "s3://bucket/team/main.duck?region=eu-west-1", "/shared/common.duck", "s3://bucket/shared/common.duck?region=eu-west-1"},
+		{"gs subdirectory", "gs://bucket/team/main.duck", "lib/common.duck", "gs://bucket/team/lib/common.duck"},
+		{"azblob sibling", "azblob://container/team/main.duck", "common.duck", "azblob://container/team/common.duck"},
+		{"azblob absolute url", "azblob://container/team/main.duck", "gs://other/common.duck", "gs://other/common.duck"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, err := url.Parse(tt.parent)
+			require.NoError(t, err)
+
+			got, err := resolveLocation(*parent, tt.location)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveLocationRemoteCannotNameLocalFile(t *testing.T) {
+	for _, parent := range []string{"https://example.com/duck/main.duck", "s3://bucket/team/main.duck"} {
+		u, err := url.Parse(parent)
+		require.NoError(t, err)
+
+		_, err = resolveLocation(*u, "file:///etc/duck/common.duck")
+		require.Error(t, err, parent)
+	}
+}
+
+func TestResolveLocationUnsupportedScheme(t *testing.T) {
+	parent, err := url.Parse("ftp://example.com/main.duck")
+	require.NoError(t, err)
+
+	_, err = resolveLocation(*parent, "common.duck")
+	require.Error(t, err)
+}
+
+// newTestDuck returns a duck compiling the given duckfiles with default settings.
+func newTestDuck(files ...string) *Duck {
+	return &Duck{
+		Config:    Config{Files: files},
+		Duckfiles: make(map[string]url.URL),
+		Targets:   make(map[string]*target.Target),
+		disabled:  make(map[string]string),
+		abstract:  make(map[string]string),
+	}
+}
+
+func TestCompileTargetsResolvesFileDependencies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "team"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "team", "main.duck"), []byte(`
+_meta:
+  dependencies: [../lib/common.duck]
+default:
+  dependencies: [common]
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "common.duck"), []byte(`
+common:
+  actions: [{type: dummy}]
+`), 0644))
+
+	// run from somewhere else so paths relative to the working directory would miss
+	t.Chdir(t.TempDir())
+
+	d := newTestDuck(filepath.Join(dir, "team", "main.duck"))
+	require.NoError(t, d.CompileTargets(context.Background()))
+	require.Contains(t, d.Targets, "common")
+	require.Equal(t, "file://"+filepath.Join(dir, "lib", "common.duck"), d.Targets["common"].Source)
+}
+
+func TestCompileTargetsResolvesHTTPDependencies(t *testing.T) {
+	files := map[string]string{
+		"/team/main.duck": `
+_meta:
+  dependencies: [common.duck, "../shared/base.duck"]
+default:
+  dependencies: [common, base]
+`,
+		"/team/common.duck": "common:\n  actions: [{type: dummy}]\n",
+		"/shared/base.duck": "base:\n  actions: [{type: dummy}]\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	d := newTestDuck(server.URL + "/team/main.duck")
+	require.NoError(t, d.CompileTargets(context.Background()))
+	require.Equal(t, server.URL+"/team/common.duck", d.Targets["common"].Source)
+	require.Equal(t, server.URL+"/shared/base.duck", d.Targets["base"].Source)
+}
+
+// testBuckets serves the ducktest:// blob scheme from local directories, by bucket name, so
+// duckfiles in blob storage can be tested without a cloud account.
+var testBuckets = &testBucketOpener{dirs: make(map[string]string)}
+
+func init() {
+	blob.DefaultURLMux().RegisterBucket("ducktest", testBuckets)
+}
+
+type testBucketOpener struct {
+	mu   sync.Mutex
+	dirs map[string]string
+}
+
+func (o *testBucketOpener) OpenBucketURL(ctx context.Context, u *url.URL) (*blob.Bucket, error) {
+	o.mu.Lock()
+	dir, ok := o.dirs[u.Host]
+	o.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no test bucket %s", u.Host)
+	}
+	return fileblob.OpenBucket(dir, nil)
+}
+
+// writeTestBucket fills a new test bucket with files, by key, and returns its name.
+func writeTestBucket(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for key, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	testBuckets.mu.Lock()
+	defer testBuckets.mu.Unlock()
+	name := fmt.Sprintf("bucket%d", len(testBuckets.dirs))
+	testBuckets.dirs[name] = dir
+	return name
+}
+
+func TestCompileTargetsResolvesBlobDependencies(t *testing.T) {
+	bucket := writeTestBucket(t, map[string]string{
+		"team/main.duck": `
+_meta:
+  dependencies: [common.duck, /shared/base.duck]
+default:
+  dependencies: [common, base]
+`,
+		"team/common.duck": "common:\n  actions: [{type: dummy}]\n",
+		"shared/base.duck": "base:\n  actions: [{type: dummy}]\n",
+	})
+
+	d := newTestDuck("ducktest://" + bucket + "/team/main.duck")
+	require.NoError(t, d.CompileTargets(context.Background()))
+	require.Equal(t, "ducktest://"+bucket+"/team/common.duck", d.Targets["common"].Source)
+	require.Equal(t, "ducktest://"+bucket+"/shared/base.duck", d.Targets["base"].Source)
+}
+
+func TestCompileTargetsRejectsLocalFilesFromRemoteDuckfiles(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "local.duck")
+	require.NoError(t, os.WriteFile(local, []byte("local:\n  actions: [{type: dummy}]\n"), 0644))
+	bucket := writeTestBucket(t, map[string]string{
+		"main.duck": fmt.Sprintf("_meta:\n  dependencies: [%q]\ndefault:\n  dependencies: [local]\n", "file://"+local),
+	})
+
+	d := newTestDuck("ducktest://" + bucket + "/main.duck")
+	require.ErrorContains(t, d.CompileTargets(context.Background()), "cannot depend on local file")
+	require.NotContains(t, d.Targets, "local")
+}
+
+func TestCompileTargetsExtendsMergesQualifiedDependencies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.duck"), []byte(`
+_meta:
+  dependencies: [{url: lib.duck, as: lib}]
+base:
+  dependencies: [setup]
+  actions: [{type: dummy}]
+setup:
+  actions: [{type: dummy}]
+plain:
+  actions: [{type: dummy}]
+default:
+  extends: base
+  dependencies: [plain]
+imported:
+  extends: lib::base
+  dependencies: [plain]
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.duck"), []byte(`
+base:
+  dependencies: [setup]
+  actions: [{type: dummy}]
+setup:
+  actions: [{type: dummy}]
+`), 0644))
+
+	d := newTestDuck(filepath.Join(dir, "main.duck"))
+	require.NoError(t, d.CompileTargets(context.Background()))
+	require.Equal(t, []string{"setup", "plain"}, d.Targets["default"].Dependencies)
+	require.Equal(t, []string{"lib::setup", "plain"}, d.Targets["imported"].Dependencies)
+}
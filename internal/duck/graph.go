@@ -0,0 +1,148 @@
+package duck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+)
+
+// Graph is the dependency graph of the compiled targets. An edge points from a target to each
+// target it depends on. Unlike the graph RunTargets walks, edges that loop back are kept.
+type Graph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// GraphNode is a target in a Graph along with what it is made of.
+type GraphNode struct {
+	Name    string
+	Source  string // url of the duckfile the target was loaded from
+	Checks  int
+	Actions int
+	Tags    []string
+}
+
+// GraphEdge records that From depends on To.
+type GraphEdge struct {
+	From string
+	To   string
+}
+
+// Graph compiles the targets if needed and returns their dependency graph. When from names any
+// targets, the graph is limited to those targets and everything they depend on, directly or not.
+// Nodes are sorted by name and edges follow the order dependencies are declared in.
+func (d *Duck) Graph(ctx context.Context, from []string) (*Graph, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	if len(d.Targets) == 0 {
+		if err := d.CompileTargets(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+		}
+	}
+
+	var names []string
+	if len(from) == 0 {
+		for name := range d.Targets {
+			names = append(names, name)
+		}
+	} else {
+		seen := make(map[string]struct{})
+		queue := slices.Clone(from)
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			t, ok := d.Targets[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, d.missingTarget(name))
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+			queue = append(queue, t.Dependencies...)
+		}
+	}
+	slices.Sort(names)
+
+	g := &Graph{}
+	for _, name := range names {
+		t := d.Targets[name]
+		g.Nodes = append(g.Nodes, GraphNode{
+			Name:    name,
+			Source:  t.Source,
+			Checks:  len(t.Checks),
+			Actions: len(t.Actions),
+			Tags:    t.Tags,
+		})
+		for _, dependency := range t.Dependencies {
+			g.Edges = append(g.Edges, GraphEdge{From: name, To: dependency})
+		}
+	}
+	return g, nil
+}
+
+// label returns the lines describing a node: its name, source, step counts and tags.
+func (n GraphNode) label() []string {
+	lines := []string{n.Name, n.Source, fmt.Sprintf("%d checks, %d actions", n.Checks, n.Actions)}
+	if len(n.Tags) > 0 {
+		lines = append(lines, "tags: "+strings.Join(n.Tags, ", "))
+	}
+	return lines
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	var b strings.Builder
+	b.WriteString("digraph duck {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		lines := n.label()
+		for i, line := range lines {
+			lines[i] = quote.Replace(line)
+		}
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\"];\n", quote.Replace(n.Name), strings.Join(lines, `\n`))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\";\n", quote.Replace(e.From), quote.Replace(e.To))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Target names are not valid Mermaid node
+// ids in general, so nodes are numbered and the name is shown in the label.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	quote := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+	ids := make(map[string]string, len(g.Nodes))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+		lines := n.label()
+		for i, line := range lines {
+			lines[i] = quote.Replace(line)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n.Name], strings.Join(lines, "<br/>"))
+	}
+	for _, e := range g.Edges {
+		to, ok := ids[e.To]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], to)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,98 @@
+package duck
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// NamespaceSeparator joins a namespace and a target name, as in common::setup.
+const NamespaceSeparator = "::"
+
+var alias = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// dependency is an entry of _meta.dependencies: a duckfile location and the namespace its
+// targets are imported under, empty to import them alongside the targets of the parent.
+type dependency struct {
+	location string
+	as       string
+}
+
+// dependencies returns the _meta.dependencies of a duckfile. An entry is either a location or
+// a map with a url and an optional alias under as.
+func dependencies(k *koanf.Koanf) ([]dependency, error) {
+	raw, ok := k.Get("_meta" + ModifiedColon + "dependencies").([]interface{})
+	if !ok {
+		if k.Exists("_meta" + ModifiedColon + "dependencies") {
+			return nil, fmt.Errorf("_meta.dependencies must be a list")
+		}
+		return nil, nil
+	}
+
+	var deps []dependency
+	for i, entry := range raw {
+		switch entry := entry.(type) {
+		case string:
+			deps = append(deps, dependency{location: entry})
+		case map[string]interface{}:
+			location, _ := entry["url"].(string)
+			if location == "" {
+				return nil, fmt.Errorf("_meta.dependencies entry %d has no url", i)
+			}
+			as, _ := entry["as"].(string)
+			if entry["as"] != nil && !alias.MatchString(as) {
+				return nil, fmt.Errorf("_meta.dependencies entry %d has an invalid alias %v, use letters, digits, - and _", i, entry["as"])
+			}
+			deps = append(deps, dependency{location: location, as: as})
+		default:
+			return nil, fmt.Errorf("_meta.dependencies entry %d must be a url or a map with url and as", i)
+		}
+	}
+	return deps, nil
+}
+
+// qualify returns name inside namespace, name itself in the global namespace.
+func qualify(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + NamespaceSeparator + name
+}
+
+// resolve returns the qualified name of the target a duckfile in namespace refers to as name:
+// the target of that name in its own namespace if there is one, else in the enclosing
+// namespaces, and finally name as it is. Names are looked up among every target definition,
+// so a reference to a disabled or abstract target still resolves.
+func (d *Duck) resolve(namespace string, name string) string {
+	for {
+		if _, ok := d.definitions[qualify(namespace, name)]; ok {
+			return qualify(namespace, name)
+		}
+		if namespace == "" {
+			return name
+		}
+		if i := strings.LastIndex(namespace, NamespaceSeparator); i >= 0 {
+			namespace = namespace[:i]
+		} else {
+			namespace = ""
+		}
+	}
+}
+
+// qualifyReferences rewrites the dependencies and extends of a target definition from a
+// duckfile in namespace to the qualified names they resolve to.
+func (d *Duck) qualifyReferences(namespace string, k *koanf.Koanf) {
+	if k.Exists("dependencies") {
+		// []interface{} like every list read from a duckfile, which is what extend expects
+		var qualified []interface{}
+		for _, name := range k.Strings("dependencies") {
+			qualified = append(qualified, d.resolve(namespace, name))
+		}
+		k.Set("dependencies", qualified)
+	}
+	if parent := k.String("extends"); parent != "" {
+		k.Set("extends", d.resolve(namespace, parent))
+	}
+}
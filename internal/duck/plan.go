@@ -0,0 +1,228 @@
+package duck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mad-weaver/duck/internal/actions"
+	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/duckerr"
+)
+
+// Plan describes the targets RunTargets would visit for the selected entry points, in the order
+// it would start them when running one target at a time, without executing any check or action.
+type Plan struct {
+	Targets []string   `json:"targets"`
+	Steps   []PlanStep `json:"steps"`
+}
+
+// PlanStep is a single target in a Plan. Dependencies only lists the targets this step
+// waits on, dependencies that were skipped because they loop back are left out.
+type PlanStep struct {
+	Target       string     `json:"target"`
+	Source       string     `json:"source"`
+	Schedule     string     `json:"schedule,omitempty"` // when the target runs in daemon mode, if it has a schedule
+	Dependencies []string   `json:"dependencies"`
+	Checks       []PlanItem `json:"checks"`
+	Actions      []PlanItem `json:"actions"`
+	OnFailure    []PlanItem `json:"on_failure,omitempty"`
+	Always       []PlanItem `json:"always,omitempty"`
+	Handlers     []PlanItem `json:"handlers,omitempty"`
+}
+
+// PlanItem is a check or action along with its resolved configuration and parameters.
+type PlanItem struct {
+	Index  int                    `json:"index"`
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+
+	Rollback *PlanItem `json:"rollback,omitempty"` // action undoing this one if a later action fails
+}
+
+// Plan compiles the targets if needed and resolves the execution plan for the targets selected
+// by the configuration. The dependency graph is walked the same way RunTargets walks it.
+func (d *Duck) Plan(ctx context.Context) (*Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context cancelled before execution: %w", err)
+	}
+
+	if len(d.Targets) == 0 {
+		if err := d.CompileTargets(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+		}
+	}
+
+	roots, err := d.SelectTargets()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", duckerr.ErrConfigInvalid, err)
+	}
+
+	g, err := d.buildGraph(roots, make(map[string]struct{}))
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Targets: roots, Steps: []PlanStep{}}
+	for _, name := range g.order {
+		t := d.Targets[name]
+		step := PlanStep{
+			Target:       name,
+			Source:       t.Source,
+			Dependencies: g.deps[name],
+			Checks:       []PlanItem{},
+		}
+		if t.Schedule != nil {
+			step.Schedule = t.Schedule.String()
+		}
+		for i, check := range t.Checks {
+			item, err := newPlanItem(i, check)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe check %d of target %s: %w", i, name, err)
+			}
+			step.Checks = append(step.Checks, item)
+		}
+		if step.Actions, err = newPlanItems(name, "action", t.Actions); err != nil {
+			return nil, err
+		}
+		for i, rollback := range t.Rollbacks {
+			if rollback == nil {
+				continue
+			}
+			item, err := newPlanItem(i, rollback)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe rollback of action %d of target %s: %w", i, name, err)
+			}
+			step.Actions[i].Rollback = &item
+		}
+		if step.OnFailure, err = newPlanItems(name, "on_failure action", t.OnFailure); err != nil {
+			return nil, err
+		}
+		if step.Always, err = newPlanItems(name, "always action", t.Always); err != nil {
+			return nil, err
+		}
+		if step.Handlers, err = newPlanItems(name, "handler", t.Handlers); err != nil {
+			return nil, err
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan, nil
+}
+
+// newPlanItems describes a list of actions of the named target.
+func newPlanItems(name string, label string, list []actions.Action) ([]PlanItem, error) {
+	items := []PlanItem{}
+	for i, action := range list {
+		item, err := newPlanItem(i, action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe %s %d of target %s: %w", label, i, name, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// newPlanItem flattens a hydrated check or action back into its duckfile form.
+func newPlanItem(index int, v interface{}) (PlanItem, error) {
+	dump, err := confighelper.GetConfigHelper().Dump(v, "mapstructure")
+	if err != nil {
+		return PlanItem{}, err
+	}
+
+	// round trip through json so pointers and nested structs print as plain values
+	raw, err := json.Marshal(dump)
+	if err != nil {
+		return PlanItem{}, err
+	}
+	var flat struct {
+		Type   string                 `json:"type"`
+		Config map[string]interface{} `json:"config"`
+		Params map[string]interface{} `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &flat); err != nil {
+		return PlanItem{}, err
+	}
+
+	return PlanItem{
+		Index:  index,
+		Type:   flat.Type,
+		Config: flat.Config,
+		Params: flat.Params,
+	}, nil
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteText writes the plan in a human readable form, one numbered block per target.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan for %s (%d targets)\n", strings.Join(p.Targets, ", "), len(p.Steps))
+
+	for i, step := range p.Steps {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, step.Target)
+		if len(step.Dependencies) > 0 {
+			fmt.Fprintf(&b, " (after %s)", strings.Join(step.Dependencies, ", "))
+		}
+		fmt.Fprintf(&b, "\n   source: %s\n", step.Source)
+		if step.Schedule != "" {
+			fmt.Fprintf(&b, "   schedule: %s\n", step.Schedule)
+		}
+		if err := writePlanItems(&b, "checks", step.Checks); err != nil {
+			return err
+		}
+		if err := writePlanItems(&b, "actions", step.Actions); err != nil {
+			return err
+		}
+		if len(step.OnFailure) > 0 {
+			if err := writePlanItems(&b, "on_failure", step.OnFailure); err != nil {
+				return err
+			}
+		}
+		if len(step.Always) > 0 {
+			if err := writePlanItems(&b, "always", step.Always); err != nil {
+				return err
+			}
+		}
+		if len(step.Handlers) > 0 {
+			if err := writePlanItems(&b, "handlers", step.Handlers); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writePlanItems(b *strings.Builder, label string, items []PlanItem) error {
+	if len(items) == 0 {
+		fmt.Fprintf(b, "   %s: none\n", label)
+		return nil
+	}
+
+	fmt.Fprintf(b, "   %s:\n", label)
+	for _, item := range items {
+		params, err := json.Marshal(item.Params)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "     [%d] %s %s\n", item.Index, item.Type, params)
+		if item.Rollback != nil {
+			params, err := json.Marshal(item.Rollback.Params)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "         rollback: %s %s\n", item.Rollback.Type, params)
+		}
+	}
+	return nil
+}
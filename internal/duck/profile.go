@@ -0,0 +1,52 @@
+package duck
+
+import (
+	"fmt"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// applyProfile merges the active profile of a duckfile, found under _meta.profiles.<name>, on
+// top of the rest of the document. A profile is a partial duckfile: its vars are merged into
+// _meta.vars and every entry under targets is merged into the target of the same name, so a
+// profile can change any target setting or disable a target with enabled: false. Returns
+// whether the duckfile defines the profile.
+func (d *Duck) applyProfile(k *koanf.Koanf) (bool, error) {
+	if d.Config.Profile == "" {
+		return false, nil
+	}
+
+	key := "_meta" + ModifiedColon + "profiles" + ModifiedColon + d.Config.Profile
+	if !k.Exists(key) {
+		return false, nil
+	}
+	profile := k.Cut(key)
+
+	overlay := koanf.New(ModifiedColon)
+	if profile.Exists("vars") {
+		if err := overlay.Set("_meta"+ModifiedColon+"vars", profile.Get("vars")); err != nil {
+			return true, err
+		}
+	}
+	for _, name := range profile.MapKeys("targets") {
+		if err := overlay.Set(name, profile.Get("targets"+ModifiedColon+name)); err != nil {
+			return true, err
+		}
+	}
+	for _, key := range profile.MapKeys("") {
+		if key != "vars" && key != "targets" {
+			return true, fmt.Errorf("unknown key %s in profile %s, expected vars or targets", key, d.Config.Profile)
+		}
+	}
+
+	if err := k.Merge(overlay); err != nil {
+		return true, fmt.Errorf("failed to apply profile %s: %w", d.Config.Profile, err)
+	}
+	return true, nil
+}
+
+// enabled reports whether the target defined by k takes part in the run, targets are enabled
+// unless they set enabled: false.
+func enabled(k *koanf.Koanf) bool {
+	return !k.Exists("enabled") || k.Bool("enabled")
+}
@@ -0,0 +1,225 @@
+package duck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/mad-weaver/duck/internal/target"
+)
+
+// dependencyGraph holds every target reachable from a set of entry points and the
+// edges between them. order lists the targets depth-first with dependencies ahead of
+// the targets that need them, which is the order the sequential walker used to run them.
+type dependencyGraph struct {
+	order      []string
+	index      map[string]int
+	deps       map[string][]string
+	dependents map[string][]string
+}
+
+// buildGraph walks the dependencies of the given root targets and returns the resulting graph.
+// Targets already present in lineage are treated as scheduled elsewhere and left out, and an edge
+// back to a target that is still being walked is dropped so loops can't deadlock the scheduler.
+// Every target added to the graph is recorded in lineage.
+func (d *Duck) buildGraph(roots []string, lineage map[string]struct{}) (*dependencyGraph, error) {
+	g := &dependencyGraph{
+		index:      make(map[string]int),
+		deps:       make(map[string][]string),
+		dependents: make(map[string][]string),
+	}
+	walking := make(map[string]bool)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		t, exists := d.Targets[name]
+		if !exists {
+			return d.missingTarget(name)
+		}
+		if _, seen := g.deps[name]; seen {
+			return nil
+		}
+
+		walking[name] = true
+		g.deps[name] = []string{}
+		for _, dependency := range t.Dependencies {
+			if _, exists := lineage[dependency]; exists {
+				slog.Debug("dependency already enqueued, skipping", "target", name, "dependency", dependency)
+				continue
+			}
+			if walking[dependency] {
+				slog.Debug("dependency loops back to a target being walked, skipping", "target", name, "dependency", dependency)
+				continue
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+			if slices.Contains(g.deps[name], dependency) {
+				continue
+			}
+			g.deps[name] = append(g.deps[name], dependency)
+			g.dependents[dependency] = append(g.dependents[dependency], name)
+		}
+		walking[name] = false
+
+		g.index[name] = len(g.order)
+		g.order = append(g.order, name)
+		return nil
+	}
+
+	for _, root := range roots {
+		if _, exists := lineage[root]; exists {
+			slog.Debug("target already in enqueued, skipping to avoid loops", "target", root)
+			continue
+		}
+		if err := visit(root); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range g.order {
+		lineage[name] = struct{}{}
+	}
+
+	return g, nil
+}
+
+// runGraph executes the targets in the graph, starting each one as soon as all of its
+// dependencies have finished. At most maxParallel targets run at once, 0 means no limit.
+// When several targets are ready the one that comes first in the graph order wins, so a limit
+// of 1 runs targets in the same order as a sequential depth-first walk. Once a target returns
+// an error, or duck starts shutting down (see WithShutdown), no new targets are started; targets
+// already running are allowed to finish and every error collected is returned. If the error
+// requests an exit, the targets still running are cancelled instead and whatever they return is
+// discarded. The targets that were started are returned alongside the error.
+func (d *Duck) runGraph(ctx context.Context, g *dependencyGraph, maxParallel int) (map[string]struct{}, error) {
+	type result struct {
+		name string
+		err  error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	exiting := false
+
+	waiting := make(map[string]int, len(g.order))
+	var ready []string
+	for _, name := range g.order {
+		waiting[name] = len(g.deps[name])
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	results := make(chan result)
+	running := 0
+	started := make(map[string]struct{})
+	var errs []error
+
+	for {
+		for len(errs) == 0 && len(ready) > 0 && (maxParallel == 0 || running < maxParallel) {
+			if shuttingDown(ctx) {
+				slog.Debug("shutting down, no further targets will be started", "ready", ready)
+				errs = append(errs, fmt.Errorf("%w: shutting down before target %s started", duckerr.ErrInterrupted, ready[0]))
+				break
+			}
+			name := ready[0]
+			ready = ready[1:]
+
+			if d.Targets[name].Cleared {
+				slog.Debug("target already cleared, skipping", "target", name)
+				ready = d.release(g, name, waiting, ready)
+				continue
+			}
+
+			slog.Debug("running target", "target", name, "running", running+1)
+			running++
+			started[name] = struct{}{}
+			targetCtx := target.WithUpstreamChanged(ctx, d.upstreamChanged(name))
+			go func(name string) {
+				results <- result{name: name, err: d.Targets[name].Run(targetCtx)}
+			}(name)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		if exiting {
+			slog.Debug("target finished after exit was requested, discarding result", "target", r.name, "error", r.err)
+			continue
+		}
+		if r.err != nil {
+			slog.Debug("target failed, no further targets will be started", "target", r.name, "error", r.err)
+			errs = append(errs, r.err)
+			if errors.Is(r.err, duckerr.ErrExitRequested) {
+				slog.Debug("exit requested, cancelling running targets", "target", r.name, "running", running)
+				exiting = true
+				cancel()
+			}
+			continue
+		}
+		ready = d.release(g, r.name, waiting, ready)
+	}
+
+	return started, errors.Join(errs...)
+}
+
+// collectResults gathers the outcome of every target in the graph in graph order. Targets
+// that were never started are reported as not run, targets that were already cleared before
+// the graph ran are left out.
+func (d *Duck) collectResults(ctx context.Context, g *dependencyGraph, started map[string]struct{}) []*report.TargetResult {
+	results := make([]*report.TargetResult, 0, len(g.order))
+	for _, name := range g.order {
+		t := d.Targets[name]
+		if _, ok := started[name]; ok && t.Result != nil {
+			results = append(results, t.Result)
+			continue
+		}
+		if t.Cleared {
+			continue
+		}
+
+		result := report.NewTargetResult(name, t.Source)
+		reason := "not started because an earlier target stopped the run"
+		if ctx.Err() != nil || shuttingDown(ctx) {
+			reason = "not started because the run was interrupted"
+		}
+		result.Finish(report.StatusNotRun, reason)
+		result.Duration = 0
+		results = append(results, result)
+	}
+	return results
+}
+
+// upstreamChanged reports whether any dependency of the named target changed something the
+// last time it ran. Dependencies run before their dependents, so their results are final.
+func (d *Duck) upstreamChanged(name string) bool {
+	for _, dependency := range d.Targets[name].Dependencies {
+		if t, ok := d.Targets[dependency]; ok && t.Result != nil && t.Result.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// release marks a target as finished and moves any dependents that no longer wait on
+// anything into the ready queue, keeping the queue sorted by graph order.
+func (d *Duck) release(g *dependencyGraph, name string, waiting map[string]int, ready []string) []string {
+	for _, dependent := range g.dependents[name] {
+		waiting[dependent]--
+		if waiting[dependent] == 0 {
+			ready = append(ready, dependent)
+		}
+	}
+	slices.SortFunc(ready, func(a, b string) int {
+		return g.index[a] - g.index[b]
+	})
+	return ready
+}
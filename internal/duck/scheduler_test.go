@@ -0,0 +1,161 @@
+package duck
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+// compileTestDuck writes content to a duckfile and returns a duck compiled from it.
+func compileTestDuck(t *testing.T, content string) *Duck {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.duck")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	d := newTestDuck(path)
+	require.NoError(t, d.CompileTargets(context.Background()))
+	return d
+}
+
+// sleepTarget is a duckfile target sleeping for a tenth of a second, after the given dependencies.
+func sleepTarget(name string, dependencies ...string) string {
+	deps := ""
+	if len(dependencies) > 0 {
+		deps = "  dependencies: [" + strings.Join(dependencies, ", ") + "]\n"
+	}
+	return name + ":\n" + deps + "  actions: [{type: shell, params: {command: sleep, args: [\"0.1\"]}}]\n"
+}
+
+// maxOverlap returns the largest number of results whose runs overlap in time.
+func maxOverlap(results []*report.TargetResult) int {
+	most := 0
+	for _, a := range results {
+		overlap := 0
+		for _, b := range results {
+			bEnd := b.Started.Add(time.Duration(b.Duration * float64(time.Second)))
+			if !b.Started.After(a.Started) && bEnd.After(a.Started) {
+				overlap++
+			}
+		}
+		most = max(most, overlap)
+	}
+	return most
+}
+
+func TestBuildGraphOrdersDependenciesFirst(t *testing.T) {
+	d := compileTestDuck(t, sleepTarget("a")+sleepTarget("b", "a")+sleepTarget("c", "a")+sleepTarget("default", "b", "c"))
+
+	g, err := d.buildGraph([]string{"default"}, make(map[string]struct{}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c", "default"}, g.order)
+	require.Equal(t, []string{"b", "c"}, g.deps["default"])
+	require.ElementsMatch(t, []string{"b", "c"}, g.dependents["a"])
+}
+
+func TestBuildGraphSkipsLineage(t *testing.T) {
+	d := compileTestDuck(t, sleepTarget("a")+sleepTarget("default", "a"))
+
+	g, err := d.buildGraph([]string{"default"}, map[string]struct{}{"a": {}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"default"}, g.order)
+}
+
+func TestBuildGraphMissingTarget(t *testing.T) {
+	d := compileTestDuck(t, sleepTarget("a"))
+
+	_, err := d.buildGraph([]string{"missing"}, make(map[string]struct{}))
+	require.Error(t, err)
+}
+
+func TestRunGraphLimitOneRunsInGraphOrder(t *testing.T) {
+	d := compileTestDuck(t, sleepTarget("a")+sleepTarget("b")+sleepTarget("c", "a")+sleepTarget("default", "c", "b"))
+	d.Config.MaxParallel = 1
+
+	results, err := d.RunTargets(context.Background(), []string{"default"}, make(map[string]struct{}))
+	require.NoError(t, err)
+
+	var started []string
+	slices.SortFunc(results, func(a, b *report.TargetResult) int { return a.Started.Compare(b.Started) })
+	for _, result := range results {
+		started = append(started, result.Id)
+	}
+	require.Equal(t, []string{"a", "c", "b", "default"}, started)
+	require.Equal(t, 1, maxOverlap(results))
+}
+
+func TestRunGraphRespectsLimit(t *testing.T) {
+	d := compileTestDuck(t, sleepTarget("a")+sleepTarget("b")+sleepTarget("c")+sleepTarget("e")+sleepTarget("default", "a", "b", "c", "e"))
+	d.Config.MaxParallel = 2
+
+	results, err := d.RunTargets(context.Background(), []string{"default"}, make(map[string]struct{}))
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.Equal(t, 2, maxOverlap(results))
+}
+
+func TestRunGraphExitRequestCancelsRunningTargets(t *testing.T) {
+	d := compileTestDuck(t, `
+slow:
+  actions: [{type: shell, params: {command: sleep, args: ["5"]}}]
+broken:
+  config: {exitOnActionFailure: true}
+  actions: [{type: shell, params: {command: /nonexistent/duck-test-command}}]
+default:
+  dependencies: [slow, broken]
+  actions: [{type: dummy}]
+`)
+	d.Config.MaxParallel = 0
+
+	start := time.Now()
+	results, err := d.RunTargets(context.Background(), []string{"default"}, make(map[string]struct{}))
+	require.Error(t, err)
+	require.True(t, errors.Is(err, duckerr.ErrExitRequested))
+	require.Less(t, time.Since(start), 3*time.Second)
+
+	statuses := make(map[string]report.Status)
+	for _, result := range results {
+		statuses[result.Id] = result.Status
+	}
+	require.Equal(t, report.StatusCancelled, statuses["broken"])
+	require.Equal(t, report.StatusNotRun, statuses["default"])
+}
+
+func TestCleanupRunsAfterExitRequest(t *testing.T) {
+	d := compileTestDuck(t, `
+slow:
+  actions: [{type: shell, params: {command: sleep, args: ["5"]}}]
+  on_failure: [{type: dummy}]
+  always: [{type: dummy}]
+broken:
+  config: {exitOnActionFailure: true}
+  actions: [{type: shell, params: {command: /nonexistent/duck-test-command}}]
+default:
+  dependencies: [slow, broken]
+  actions: [{type: dummy}]
+`)
+	d.Config.MaxParallel = 0
+
+	results, err := d.RunTargets(context.Background(), []string{"default"}, make(map[string]struct{}))
+	require.ErrorIs(t, err, duckerr.ErrExitRequested)
+
+	for _, result := range results {
+		if result.Id != "slow" {
+			continue
+		}
+		require.Len(t, result.OnFailure, 1)
+		require.Equal(t, report.StatusPassed, result.OnFailure[0].Status)
+		require.Len(t, result.Always, 1)
+		require.Equal(t, report.StatusPassed, result.Always[0].Status)
+		return
+	}
+	t.Fatal("no result for target slow")
+}
@@ -0,0 +1,97 @@
+package duck
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// SelectTargets resolves the entry point targets of a run from the target patterns and the tag
+// selectors in the configuration. Each pattern is a target name or a glob such as db-*. Without
+// any pattern every target is a candidate when tags are given and the default target otherwise.
+// Candidates are then narrowed to those carrying one of Config.Tags, if any, and stripped of
+// those carrying one of Config.SkipTags. Tags only select entry points, the dependencies of a
+// selected target always run. Targets named explicitly keep the order they were given in, the
+// targets matched by a glob or selected only by tags are added in name order.
+func (d *Duck) SelectTargets() ([]string, error) {
+	patterns := d.Config.Target
+	if len(patterns) == 0 && len(d.Config.Tags) == 0 {
+		patterns = []string{"default"}
+	}
+
+	var candidates []string
+	seen := make(map[string]struct{})
+	add := func(names ...string) {
+		for _, name := range names {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		add(slices.Sorted(maps.Keys(d.Targets))...)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid target pattern %s: %w", pattern, err)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if _, exists := d.Targets[pattern]; !exists {
+				return nil, d.missingTarget(pattern)
+			}
+			add(pattern)
+			continue
+		}
+
+		var matches []string
+		for _, name := range slices.Sorted(maps.Keys(d.Targets)) {
+			if ok, _ := path.Match(pattern, name); ok {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no target matches %s", pattern)
+		}
+		add(matches...)
+	}
+
+	var selected []string
+	for _, name := range candidates {
+		tags := d.Targets[name].Tags
+		if len(d.Config.Tags) > 0 && !hasAnyTag(tags, d.Config.Tags) {
+			continue
+		}
+		if hasAnyTag(tags, d.Config.SkipTags) {
+			continue
+		}
+		selected = append(selected, name)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no targets selected by targets %v, tags %v and skip-tags %v", patterns, d.Config.Tags, d.Config.SkipTags)
+	}
+
+	return selected, nil
+}
+
+func hasAnyTag(tags []string, wanted []string) bool {
+	for _, tag := range wanted {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingTarget explains why a target name does not resolve to a runnable target.
+func (d *Duck) missingTarget(name string) error {
+	if source, disabled := d.disabled[name]; disabled {
+		return fmt.Errorf("target %s (%s) is disabled", name, source)
+	}
+	if source, abstract := d.abstract[name]; abstract {
+		return fmt.Errorf("target %s (%s) is abstract and cannot be run directly", name, source)
+	}
+	return fmt.Errorf("target %s not found", name)
+}
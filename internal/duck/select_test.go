@@ -0,0 +1,44 @@
+package duck
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTargetsOrder(t *testing.T) {
+	d := compileTestDuck(t, `
+default:
+  actions: [{type: dummy}]
+web:
+  tags: [app]
+  actions: [{type: dummy}]
+db-b:
+  tags: [app]
+  actions: [{type: dummy}]
+db-a:
+  actions: [{type: dummy}]
+`)
+
+	tests := []struct {
+		name     string
+		targets  []string
+		tags     []string
+		expected []string
+	}{
+		{"default", nil, nil, []string{"default"}},
+		{"command line order", []string{"web", "db-b", "default"}, nil, []string{"web", "db-b", "default"}},
+		{"glob matches sorted", []string{"web", "db-*"}, nil, []string{"web", "db-a", "db-b"}},
+		{"duplicates dropped", []string{"db-b", "db-*", "db-b"}, nil, []string{"db-b", "db-a"}},
+		{"tags only sorted", nil, []string{"app"}, []string{"db-b", "web"}},
+		{"tags keep order", []string{"web", "db-a", "db-b"}, []string{"app"}, []string{"web", "db-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d.Config.Target, d.Config.Tags = tt.targets, tt.tags
+			selected, err := d.SelectTargets()
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, selected)
+		})
+	}
+}
@@ -4,20 +4,46 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 
 	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
 	"github.com/mad-weaver/duck/internal/target"
+	"github.com/mad-weaver/duck/internal/vars"
 )
 
 // appendTarget will unmarshal a koanf object into a target object and append it to the duck Target map.
-// accepts a context, a target name, and a koanf object. sets target ID and its map key to the "name" parameter.
-func (d *Duck) appendTarget(ctx context.Context, name string, konfig *koanf.Koanf) error {
+// accepts a context, a target name, the url of the duckfile it came from, and a koanf object. sets target
+// ID and its map key to the "name" parameter. The definition is merged on top of the target it
+// extends, if any, and variables are substituted before the target is hydrated.
+func (d *Duck) appendTarget(ctx context.Context, name string, source string, konfig *koanf.Koanf) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled before execution: %w", err)
 	}
 
 	if _, exists := d.Targets[name]; exists {
-		return fmt.Errorf("target %s already exists", name)
+		return fmt.Errorf("target %s already exists in %s", name, d.Targets[name].Source)
+	}
+
+	konfig, err := d.extend(name, konfig, nil)
+	if err != nil {
+		return err
+	}
+	// registered values and foreach items only exist once the target runs or its steps are expanded
+	store := vars.NewStore()
+	for name, value := range d.Vars {
+		store.Set(name, value)
+	}
+	for _, name := range d.registered {
+		store.Defer("vars." + name)
+	}
+	ctx = vars.WithStore(ctx, store)
+
+	konfig, err = vars.Render(konfig, map[string]interface{}{"vars": store.Values()}, append(store.Deferred(), "item", "key"))
+	if err != nil {
+		return fmt.Errorf("failed to substitute variables: %w", err)
 	}
 	konfig.Set("id", name)
 
@@ -25,6 +51,7 @@ func (d *Duck) appendTarget(ctx context.Context, name string, konfig *koanf.Koan
 	if err != nil {
 		return fmt.Errorf("failed to create target %s: %w", name, err)
 	}
+	target.Source = source
 
 	slog.Debug("appending target", "name", name, "konfig", konfig)
 	d.Targets[name] = target
@@ -44,55 +71,41 @@ func (d *Duck) ListTargets(ctx context.Context) error {
 		}
 	}
 
-	for target := range d.Targets {
+	if d.Config.Profile != "" {
+		fmt.Printf("Active profile: %s\n", d.Config.Profile)
+	}
+	// targets imported from aliased dependencies are listed under their qualified names
+	for _, target := range slices.Sorted(maps.Keys(d.Targets)) {
 		fmt.Println(target)
 	}
 
 	return nil
 }
 
-// RunTarget will run the target specified by the target name.
-// accepts a context, a target name, and a lineage map. lineage is a hash
+// RunTarget will run the target specified by the target name along with everything it depends on.
+// accepts a context, a target name, and a lineage map. See RunTargets.
+func (d *Duck) RunTarget(ctx context.Context, target string, lineage map[string]struct{}) ([]*report.TargetResult, error) {
+	return d.RunTargets(ctx, []string{target}, lineage)
+}
+
+// RunTargets will run the targets specified by the target names along with everything they depend on.
+// accepts a context, the target names, and a lineage map. lineage is a hash
 // of all targets that are scheduled to be executed and is used to detect loops
-// and avoid scheduling them. If a Target has dependent targets, it will add itself to
-// the lineage and then recursively call each depdendent target. Assumes CompileTargets
-// was called at some point before running this else this will fail.
-func (d *Duck) RunTarget(ctx context.Context, target string, lineage map[string]struct{}) error {
+// and avoid scheduling them twice. The dependency graph below the targets is built up front,
+// so a dependency shared by several targets runs once, and independent targets are run
+// concurrently, bounded by the max-parallel setting. Returns the result of every target in the
+// graph. Assumes CompileTargets was called at some point before running this else this will fail.
+func (d *Duck) RunTargets(ctx context.Context, targets []string, lineage map[string]struct{}) ([]*report.TargetResult, error) {
 	if err := ctx.Err(); err != nil {
-		return fmt.Errorf("context cancelled before execution: %w", err)
-	}
-
-	// check if the target exists
-	if _, exists := d.Targets[target]; !exists {
-		return fmt.Errorf("target %s not found", target)
+		return nil, fmt.Errorf("%w: context cancelled before execution: %w", duckerr.ErrInterrupted, err)
 	}
 
-	// check if the target is already in the lineage
-	if _, exists := lineage[target]; exists {
-		slog.Debug("target already in enqueued, skipping to avoid loops", "target", target)
-		return nil
-	}
-
-	// check if the target is already cleared
-	if d.Targets[target].Cleared {
-		slog.Debug("target already cleared, skipping", "target", target)
-		return nil
-	}
-
-	slog.Debug("running target", "target", target)
-
-	// add this target to the lineage
-	lineage[target] = struct{}{}
-
-	// check if the target has dependencies
-	if len(d.Targets[target].Dependencies) > 0 {
-		for _, dependency := range d.Targets[target].Dependencies {
-			if err := d.RunTarget(ctx, dependency, lineage); err != nil {
-				return err
-			}
-		}
+	g, err := d.buildGraph(targets, lineage)
+	if err != nil {
+		return nil, err
 	}
 
-	// run the target
-	return d.Targets[target].Run(ctx)
+	slog.Debug("running target graph", "targets", targets, "order", g.order, "max-parallel", d.Config.MaxParallel)
+	started, err := d.runGraph(ctx, g, d.Config.MaxParallel)
+	return d.collectResults(ctx, g, started), err
 }
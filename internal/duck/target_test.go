@@ -0,0 +1,146 @@
+package duck
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+// runTestTarget runs the default target of a duckfile and returns its result.
+func runTestTarget(t *testing.T, content string) (*report.TargetResult, error) {
+	t.Helper()
+	d := compileTestDuck(t, content)
+	results, err := d.Execute(context.Background(), []string{"default"})
+	for _, result := range results {
+		if result.Id == "default" {
+			return result, err
+		}
+	}
+	t.Fatal("no result for target default")
+	return nil, err
+}
+
+func TestParallelBatchRollsBackEveryPassedStep(t *testing.T) {
+	result, err := runTestTarget(t, `
+default:
+  actions:
+    - type: shell
+      foreach:
+        - {cmd: "true", timeout: 5s}
+        - {cmd: "sleep 2", timeout: 200ms}
+        - {cmd: "true", timeout: 5s}
+      parallel: 3
+      config: {timeout: "{{ .item.timeout }}"}
+      params: {command: sh, args: ["-c", "{{ .item.cmd }}"]}
+      rollback: {type: dummy}
+`)
+	require.ErrorIs(t, err, duckerr.ErrActionFailed)
+	require.Equal(t, report.StatusCancelled, result.Status)
+
+	var rolledBack []int
+	for _, rollback := range result.Rollback {
+		rolledBack = append(rolledBack, rollback.Index)
+	}
+	require.Equal(t, []int{2, 0}, rolledBack)
+}
+
+func TestParallelBatchFailureStopsSiblings(t *testing.T) {
+	start := time.Now()
+	result, err := runTestTarget(t, `
+default:
+  actions:
+    - type: shell
+      foreach: [sleep, /nonexistent/duck-test-command, sleep, sleep]
+      parallel: 2
+      params: {command: "{{ .item }}", args: ["5"]}
+`)
+	require.ErrorIs(t, err, duckerr.ErrActionFailed)
+	require.Less(t, time.Since(start), 3*time.Second)
+	require.Len(t, result.Actions, 1)
+	require.Equal(t, 1, result.Actions[0].Index)
+}
+
+func TestFailedStatusChangesNothing(t *testing.T) {
+	result, err := runTestTarget(t, `
+default:
+  actions:
+    - {type: shell, config: {notify: [restart]}, params: {command: "false"}}
+    - {type: shell, params: {command: "true"}}
+  handlers:
+    - {name: restart, type: dummy}
+`)
+	require.NoError(t, err)
+	require.Len(t, result.Actions, 2)
+	require.False(t, result.Actions[0].Changed)
+	require.True(t, result.Actions[1].Changed)
+	require.Empty(t, result.Handlers)
+}
+
+func TestRollbackUsesRegisteredOutput(t *testing.T) {
+	dir := t.TempDir()
+	result, err := runTestTarget(t, `
+default:
+  actions:
+    - type: shell
+      config: {register: made}
+      params: {command: mktemp, args: ["-p", "`+dir+`"]}
+      rollback: {type: shell, params: {command: rm, args: ["{{ .vars.made.stdout }}"]}}
+    - {type: shell, params: {command: /nonexistent/duck-test-command}}
+`)
+	require.ErrorIs(t, err, duckerr.ErrActionFailed)
+	require.Len(t, result.Rollback, 1)
+	require.Equal(t, report.StatusPassed, result.Rollback[0].Status)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "rollback should have removed the file the action made")
+}
+
+func TestOnFailureAlertRendersFailure(t *testing.T) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	_, err = runTestTarget(t, `
+default:
+  actions: [{type: shell, params: {command: /nonexistent/duck-test-command}}]
+  on_failure: [{type: print, params: {message: "{{ .failure.target }} failed: {{ .failure.reason }}"}}]
+`)
+	require.ErrorIs(t, err, duckerr.ErrActionFailed)
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	alert, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(alert), "default failed: "), string(alert))
+	require.Contains(t, string(alert), "/nonexistent/duck-test-command")
+}
+
+func TestUnitlessTimeoutIsRejected(t *testing.T) {
+	for _, content := range []string{
+		"default:\n  config: {timeout: 30}\n  actions: [{type: dummy}]\n",
+		"default:\n  actions: [{type: dummy, config: {timeout: 30}}]\n",
+		"default:\n  checks: [{type: dummy, config: {timeout: 30}}]\n",
+	} {
+		path := filepath.Join(t.TempDir(), "main.duck")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		err := newTestDuck(path).CompileTargets(context.Background())
+		require.ErrorContains(t, err, `duration 30 has no unit, write it such as "30s"`, content)
+	}
+
+	_, err := runTestTarget(t, `
+default:
+  config: {timeout: 0}
+  actions: [{type: dummy, config: {timeout: 30s}}]
+`)
+	require.NoError(t, err)
+}
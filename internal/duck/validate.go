@@ -0,0 +1,101 @@
+package duck
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValidateTargets checks the dependency graph of the compiled targets. Every dependency that does
+// not name a known target, or names a disabled or abstract one, and every dependency cycle is
+// reported, along with the duckfile each offending target was loaded from, so a broken duckfile
+// fails before any check or action runs.
+func (d *Duck) ValidateTargets() error {
+	names := make([]string, 0, len(d.Targets))
+	for name := range d.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var messages []string
+
+	for _, name := range names {
+		for _, dependency := range d.Targets[name].Dependencies {
+			if source, disabled := d.disabled[dependency]; disabled {
+				messages = append(messages, fmt.Sprintf("target '%s' (%s) depends on disabled target '%s' (%s)", name, d.Targets[name].Source, dependency, source))
+			} else if source, abstract := d.abstract[dependency]; abstract {
+				messages = append(messages, fmt.Sprintf("target '%s' (%s) depends on abstract target '%s' (%s)", name, d.Targets[name].Source, dependency, source))
+			} else if _, exists := d.Targets[dependency]; !exists {
+				messages = append(messages, fmt.Sprintf("target '%s' (%s) depends on missing target '%s'", name, d.Targets[name].Source, dependency))
+			}
+		}
+	}
+
+	for _, cycle := range d.findCycles(names) {
+		hops := make([]string, 0, len(cycle)+1)
+		for _, name := range cycle {
+			hops = append(hops, fmt.Sprintf("%s (%s)", name, d.Targets[name].Source))
+		}
+		hops = append(hops, cycle[0])
+		messages = append(messages, fmt.Sprintf("dependency cycle: %s", strings.Join(hops, " -> ")))
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("Target validation failed:\n  - %s", strings.Join(messages, "\n  - "))
+	}
+	return nil
+}
+
+// findCycles walks the dependency graph depth-first and returns one cycle for every edge that
+// leads back to a target still on the walk stack. Each cycle is rotated to start at its
+// alphabetically lowest target so the same loop found from different entry points is reported once.
+func (d *Duck) findCycles(names []string) [][]string {
+	const (
+		unvisited = iota
+		walking
+		done
+	)
+	state := make(map[string]int, len(names))
+	var stack []string
+	var cycles [][]string
+	seen := make(map[string]struct{})
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = walking
+		stack = append(stack, name)
+
+		for _, dependency := range d.Targets[name].Dependencies {
+			if _, exists := d.Targets[dependency]; !exists {
+				continue
+			}
+			switch state[dependency] {
+			case unvisited:
+				visit(dependency)
+			case walking:
+				start := slices.Index(stack, dependency)
+				cycle := slices.Clone(stack[start:])
+				lowest := slices.Index(cycle, slices.Min(cycle))
+				cycle = append(cycle[lowest:], cycle[:lowest]...)
+
+				key := strings.Join(cycle, "\x00")
+				if _, exists := seen[key]; !exists {
+					seen[key] = struct{}{}
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return cycles
+}
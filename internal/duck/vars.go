@@ -0,0 +1,39 @@
+package duck
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/vars"
+)
+
+// document is a loaded duckfile whose targets have not been compiled yet.
+type document struct {
+	url        url.URL
+	konfig     *koanf.Koanf
+	dependency bool   // loaded through the _meta.dependencies of another duckfile
+	namespace  string // alias the targets of the duckfile are imported under, empty for none
+}
+
+// resolveVars merges the _meta.vars of every loaded duckfile into d.Vars and applies the
+// overrides from DUCK_VAR_* environment variables and --set. Dependencies only provide
+// defaults: the duckfiles that pulled them in take precedence, and among those a later
+// duckfile overrides an earlier one.
+func (d *Duck) resolveVars() error {
+	d.Vars = make(map[string]interface{})
+	for _, dependency := range []bool{true, false} {
+		for _, doc := range d.documents {
+			if doc.dependency == dependency {
+				maps.Copy(d.Vars, doc.konfig.Cut("_meta"+ModifiedColon+"vars").Raw())
+			}
+		}
+	}
+
+	if err := vars.Override(d.Vars, os.Environ(), d.Config.Set); err != nil {
+		return fmt.Errorf("failed to apply variable overrides: %w", err)
+	}
+	return nil
+}
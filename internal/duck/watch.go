@@ -0,0 +1,334 @@
+package duck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gocloud.dev/blob"
+)
+
+// settleDelay is how long the watcher waits for a burst of file events to end before reporting
+// a change, as editors often write a file in several steps.
+const settleDelay = 250 * time.Millisecond
+
+// Watcher reports when the duckfiles a duck was compiled from change. Local duckfiles, and the
+// directories given with --file so new duckfiles are noticed, are watched with fsnotify. Remote
+// duckfiles are polled every PollInterval and compared by ETag or Last-Modified header for
+// http(s) and by blob attributes for blob storage, remote locations given with --file are listed
+// again to notice new duckfiles.
+type Watcher struct {
+	PollInterval time.Duration // how often remote duckfiles are polled, 0 disables polling
+
+	fs      *fsnotify.Watcher
+	changes chan struct{}
+
+	mu        sync.Mutex
+	files     map[string]bool   // local duckfiles, by path
+	dirs      map[string]bool   // local directories given with --file
+	watched   map[string]bool   // directories added to fs
+	remote    map[string]string // remote duckfiles, by url, mapped to their fingerprint
+	known     map[string]bool   // every remote duckfile loaded, fingerprinted or not
+	locations []string          // remote locations given with --file
+}
+
+// NewWatcher creates a watcher that watches nothing until Watch is called.
+func NewWatcher(pollInterval time.Duration) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	return &Watcher{
+		PollInterval: pollInterval,
+		fs:           fs,
+		changes:      make(chan struct{}, 1),
+		files:        make(map[string]bool),
+		dirs:         make(map[string]bool),
+		watched:      make(map[string]bool),
+		remote:       make(map[string]string),
+		known:        make(map[string]bool),
+	}, nil
+}
+
+// Changes returns the channel the watcher signals on when a duckfile changes. Changes made
+// while an earlier one has not been received yet are folded into it.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// watchSet is what a Watcher watches, gathered from the duckfiles a duck was compiled from.
+type watchSet struct {
+	files     map[string]bool
+	dirs      map[string]bool
+	remote    map[string]string
+	known     map[string]bool
+	locations []string
+}
+
+// Watch replaces what the watcher watches with the duckfiles d was compiled from, recording the
+// current fingerprint of every remote one. A remote duckfile that cannot be fingerprinted is
+// logged and ignored until the next Watch.
+func (w *Watcher) Watch(ctx context.Context, d *Duck) error {
+	return w.replace(newWatchSet(ctx, d))
+}
+
+// newWatchSet gathers the duckfiles d was compiled from, fingerprinting the remote ones, which
+// takes a request to each of them.
+func newWatchSet(ctx context.Context, d *Duck) *watchSet {
+	set := &watchSet{
+		files:  make(map[string]bool),
+		dirs:   make(map[string]bool),
+		remote: make(map[string]string),
+		known:  make(map[string]bool),
+	}
+
+	for _, duckfile := range d.Duckfiles {
+		if duckfile.Scheme == "file" {
+			set.files[duckfile.Path] = true
+			continue
+		}
+		set.known[duckfile.String()] = true
+		fingerprint, err := fingerprint(ctx, duckfile)
+		if err != nil {
+			slog.Warn("Cannot tell when duckfile changes, not watching it", "url", duckfile.String(), "error", err)
+			continue
+		}
+		set.remote[duckfile.String()] = fingerprint
+	}
+	for _, location := range d.Config.Files {
+		if strings.Contains(location, "://") && !strings.HasPrefix(location, "file://") {
+			set.locations = append(set.locations, location)
+			continue
+		}
+		path := strings.TrimPrefix(location, "file://")
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil {
+				set.dirs[abs] = true
+			}
+		}
+	}
+	return set
+}
+
+// replace makes the watcher watch set, adding and removing directories from fs as needed.
+func (w *Watcher) replace(set *watchSet) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for path := range set.files {
+		wanted[filepath.Dir(path)] = true
+	}
+	for dir := range set.dirs {
+		wanted[dir] = true
+	}
+	for dir := range wanted {
+		if w.watched[dir] {
+			continue
+		}
+		if err := w.fs.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		w.watched[dir] = true
+	}
+	for dir := range w.watched {
+		if !wanted[dir] {
+			_ = w.fs.Remove(dir)
+			delete(w.watched, dir)
+		}
+	}
+
+	w.files, w.dirs, w.remote, w.known, w.locations = set.files, set.dirs, set.remote, set.known, set.locations
+	return nil
+}
+
+// Run watches for changes until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	var poll <-chan time.Time
+	if w.PollInterval > 0 {
+		ticker := time.NewTicker(w.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if w.relevant(event) {
+				slog.Debug("Duckfile changed", "path", event.Name, "op", event.Op.String())
+				settle = time.After(settleDelay)
+			}
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("File watcher error", "error", err)
+		case <-settle:
+			settle = nil
+			w.signal()
+		case <-poll:
+			if w.poll(ctx) {
+				w.signal()
+			}
+		}
+	}
+}
+
+// Close stops watching local files.
+func (w *Watcher) Close() error {
+	return w.fs.Close()
+}
+
+// relevant reports whether event touches a watched duckfile or adds one to a watched directory.
+func (w *Watcher) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.files[event.Name] || (w.dirs[filepath.Dir(event.Name)] && isDuckfile(filepath.Base(event.Name)))
+}
+
+// poll reports whether any remote duckfile changed or a remote location lists a new one.
+func (w *Watcher) poll(ctx context.Context) bool {
+	w.mu.Lock()
+	remote := make(map[string]string, len(w.remote))
+	for location, fingerprint := range w.remote {
+		remote[location] = fingerprint
+	}
+	known, locations := w.known, w.locations
+	w.mu.Unlock()
+
+	for location, before := range remote {
+		u, err := url.Parse(location)
+		if err != nil {
+			continue
+		}
+		after, err := fingerprint(ctx, *u)
+		if err != nil {
+			slog.Warn("Failed to poll duckfile", "url", location, "error", err)
+			continue
+		}
+		if after != before {
+			slog.Debug("Duckfile changed", "url", location)
+			return true
+		}
+	}
+	for _, location := range locations {
+		duckfiles, err := GetDuckfiles(ctx, location)
+		if err != nil {
+			slog.Warn("Failed to list duckfiles", "location", location, "error", err)
+			continue
+		}
+		for _, duckfile := range duckfiles {
+			if !known[duckfile.String()] {
+				slog.Debug("Duckfile added", "url", duckfile.String())
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *Watcher) signal() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// fingerprint returns a value that changes whenever the remote duckfile does: its ETag, else its
+// last modification time, else a hash of its content when the server provides neither.
+func fingerprint(ctx context.Context, duckfile url.URL) (string, error) {
+	switch duckfile.Scheme {
+	case "http", "https":
+		return fingerprintHTTPURL(ctx, duckfile)
+	default:
+		if !isBlobScheme(duckfile.Scheme) {
+			return "", fmt.Errorf("unsupported scheme: %s", duckfile.Scheme)
+		}
+		return fingerprintCloudURL(ctx, duckfile)
+	}
+}
+
+func fingerprintHTTPURL(ctx context.Context, duckfile url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, duckfile.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for %s: %w", duckfile.String(), err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch from %s: %w", duckfile.String(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to fetch from %s: status %s", duckfile.String(), resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return "etag:" + etag, nil
+	}
+	if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		return "modified:" + modified, nil
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, duckfile.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for %s: %w", duckfile.String(), err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch from %s: %w", duckfile.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to fetch from %s: status %s", duckfile.String(), resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read response body from %s: %w", duckfile.String(), err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func fingerprintCloudURL(ctx context.Context, duckfile url.URL) (string, error) {
+	bucketURL := fmt.Sprintf("%s://%s%s", duckfile.Scheme, duckfile.Host, duckfile.Path)
+	if duckfile.RawQuery != "" {
+		bucketURL = fmt.Sprintf("%s?%s", bucketURL, duckfile.RawQuery)
+	}
+
+	bucket, err := blob.OpenBucket(ctx, bucketURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to open bucket %s: %w", bucketURL, err)
+	}
+	defer bucket.Close()
+
+	key := strings.TrimPrefix(duckfile.Path, "/")
+	attrs, err := bucket.Attributes(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read attributes of %s: %w", key, err)
+	}
+	if attrs.ETag != "" {
+		return "etag:" + attrs.ETag, nil
+	}
+	if len(attrs.MD5) > 0 {
+		return "md5:" + hex.EncodeToString(attrs.MD5), nil
+	}
+	return fmt.Sprintf("modified:%s:%d", attrs.ModTime.Format(time.RFC3339Nano), attrs.Size), nil
+}
@@ -0,0 +1,114 @@
+package duck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWatcher returns a watcher watching the duckfiles compiled from files.
+func newTestWatcher(t *testing.T, files ...string) *Watcher {
+	t.Helper()
+	d := newTestDuck(files...)
+	require.NoError(t, d.CompileTargets(context.Background()))
+
+	w, err := NewWatcher(0)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+	require.NoError(t, w.Watch(context.Background(), d))
+	return w
+}
+
+func TestWatcherRelevant(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.duck")
+	require.NoError(t, os.WriteFile(main, []byte("default:\n  actions: [{type: dummy}]\n"), 0644))
+	other := t.TempDir()
+
+	w := newTestWatcher(t, dir)
+
+	tests := []struct {
+		name  string
+		event fsnotify.Event
+		want  bool
+	}{
+		{"duckfile written", fsnotify.Event{Name: main, Op: fsnotify.Write}, true},
+		{"duckfile removed", fsnotify.Event{Name: main, Op: fsnotify.Remove}, true},
+		{"duckfile chmod", fsnotify.Event{Name: main, Op: fsnotify.Chmod}, false},
+		{"duckfile added", fsnotify.Event{Name: filepath.Join(dir, "new.duck"), Op: fsnotify.Create}, true},
+		{"other file added", fsnotify.Event{Name: filepath.Join(dir, "notes.txt"), Op: fsnotify.Create}, false},
+		{"duckfile elsewhere", fsnotify.Event{Name: filepath.Join(other, "main.duck"), Op: fsnotify.Write}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, w.relevant(tt.event))
+		})
+	}
+}
+
+func TestWatcherPollHTTP(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag.Load().(string))
+		w.Write([]byte("default:\n  actions: [{type: dummy}]\n"))
+	}))
+	defer server.Close()
+
+	w := newTestWatcher(t, server.URL+"/main.duck")
+	require.False(t, w.poll(context.Background()))
+
+	etag.Store(`"v2"`)
+	require.True(t, w.poll(context.Background()))
+}
+
+func TestFingerprintHTTPURLRejectsErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"head fails", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"error page"`)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}},
+		{"get fails", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				http.NotFound(w, r)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			u, err := url.Parse(server.URL + "/main.duck")
+			require.NoError(t, err)
+			_, err = fingerprintHTTPURL(context.Background(), *u)
+			require.ErrorContains(t, err, "status")
+		})
+	}
+}
+
+func TestWatcherPollBlobLocation(t *testing.T) {
+	bucket := writeTestBucket(t, map[string]string{
+		"team/main.duck": "default:\n  actions: [{type: dummy}]\n",
+	})
+
+	w := newTestWatcher(t, "ducktest://"+bucket+"/team/")
+	require.False(t, w.poll(context.Background()))
+
+	testBuckets.mu.Lock()
+	dir := testBuckets.dirs[bucket]
+	testBuckets.mu.Unlock()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "team", "new.duck"), []byte("other:\n  actions: [{type: dummy}]\n"), 0644))
+	require.True(t, w.poll(context.Background()))
+}
@@ -0,0 +1,44 @@
+// Package duckerr holds the sentinel errors that explain why a duck run stopped and maps
+// them onto the process exit codes documented for the CLI.
+package duckerr
+
+import "errors"
+
+var (
+	ErrCheckFailed   = errors.New("check failed")          // a check failed and the target was cancelled
+	ErrActionFailed  = errors.New("action failed")         // an action failed and the target was cancelled
+	ErrExitRequested = errors.New("exit requested")        // exitOnFailure was set on the failing check or action
+	ErrConfigInvalid = errors.New("invalid configuration") // duckfiles or cli options could not be loaded
+	ErrInterrupted   = errors.New("interrupted")           // the run was stopped by a termination signal
+	ErrTimeout       = errors.New("timed out")             // a check, action or target ran past its timeout
+)
+
+// Process exit codes returned by the duck binary.
+const (
+	ExitOK            = 0   // everything ran, or the daemon shut down without interrupting a run
+	ExitError         = 1   // any error not covered below
+	ExitConfigInvalid = 2   // a duckfile or option is invalid, nothing was run
+	ExitCheckFailed   = 3   // a check failed and cancelled or exited the run
+	ExitActionFailed  = 4   // an action failed and cancelled or exited the run
+	ExitInterrupted   = 130 // a termination signal stopped the run part way through
+)
+
+// ExitCode maps an error returned by the app to the process exit code. When an error wraps
+// several sentinels the most fundamental one wins: interrupted, then invalid configuration,
+// then action failure, then check failure.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrInterrupted):
+		return ExitInterrupted
+	case errors.Is(err, ErrConfigInvalid):
+		return ExitConfigInvalid
+	case errors.Is(err, ErrActionFailed):
+		return ExitActionFailed
+	case errors.Is(err, ErrCheckFailed):
+		return ExitCheckFailed
+	default:
+		return ExitError
+	}
+}
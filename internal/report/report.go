@@ -0,0 +1,120 @@
+// Package report models the outcome of a duck run, target by target and step by step,
+// and writes it out as JSON, JUnit XML or TAP so CI systems can publish it as test results.
+package report
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPassed    Status = "passed"    // check passed, action or target completed
+	StatusFailed    Status = "failed"    // check did not pass, action returned an error, or target finished with a failed action
+	StatusError     Status = "error"     // check could not be executed
+	StatusSkipped   Status = "skipped"   // target stopped quietly because a check did not pass, or action skipped by its when condition
+	StatusCancelled Status = "cancelled" // target returned an error and stopped the run
+	StatusNotRun    Status = "not_run"   // target was never started because the run stopped first
+)
+
+// RunReport is the outcome of one call to Duck.Run.
+type RunReport struct {
+	Targets  []string        `json:"targets"`         // entry point targets that were requested
+	Check    bool            `json:"check,omitempty"` // actions were previewed, not executed
+	Started  time.Time       `json:"started"`
+	Duration float64         `json:"duration"` // seconds
+	Status   Status          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Results  []*TargetResult `json:"results"`
+}
+
+// TargetResult is the outcome of a single target.
+type TargetResult struct {
+	Id        string         `json:"id"`
+	Source    string         `json:"source,omitempty"`
+	Status    Status         `json:"status"`
+	Reason    string         `json:"reason,omitempty"` // why a target was skipped, cancelled or not run
+	Started   time.Time      `json:"started"`
+	Duration  float64        `json:"duration"` // seconds
+	Checks    []CheckResult  `json:"checks"`
+	Actions   []ActionResult `json:"actions"`
+	Rollback  []ActionResult `json:"rollback,omitempty"`   // rollbacks run in reverse order after a failed action, indexed by the action rolled back
+	OnFailure []ActionResult `json:"on_failure,omitempty"` // actions run because the target failed
+	Always    []ActionResult `json:"always,omitempty"`     // actions run after the target regardless of outcome
+	Handlers  []ActionResult `json:"handlers,omitempty"`   // handlers run because an action that notifies them changed something
+	Changed   bool           `json:"changed"`              // whether any action or handler changed something
+}
+
+// CheckResult is the outcome of a single check. Status is the result of the last attempt
+// after Invert was applied.
+type CheckResult struct {
+	Index    int     `json:"index"`
+	Key      string  `json:"key,omitempty"` // foreach key the check was expanded for
+	Type     string  `json:"type"`
+	Status   Status  `json:"status"`
+	Invert   bool    `json:"invert"`
+	Attempts int     `json:"attempts"`
+	Duration float64 `json:"duration"` // seconds, across all attempts
+	Error    string  `json:"error,omitempty"`
+
+	Children []CheckResult `json:"children,omitempty"` // outcome of the nested checks of a group check
+}
+
+// ActionResult is the outcome of a single action, Status is the result of the last attempt.
+type ActionResult struct {
+	Index       int     `json:"index"`
+	Key         string  `json:"key,omitempty"` // foreach key the action was expanded for
+	Type        string  `json:"type"`
+	Status      Status  `json:"status"`
+	Attempts    int     `json:"attempts"`
+	Duration    float64 `json:"duration"` // seconds, across all attempts
+	Error       string  `json:"error,omitempty"`
+	Reason      string  `json:"reason,omitempty"`      // why the action was skipped
+	Changed     bool    `json:"changed"`               // whether the action changed anything, see actions.ChangeReporter
+	Diff        string  `json:"diff,omitempty"`        // unified diff of the change, when the action can describe it
+	Description string  `json:"description,omitempty"` // the change in words, or the change it would make in check mode
+}
+
+// NewTargetResult starts the result for a target.
+func NewTargetResult(id string, source string) *TargetResult {
+	return &TargetResult{
+		Id:      id,
+		Source:  source,
+		Started: time.Now(),
+		Checks:  []CheckResult{},
+		Actions: []ActionResult{},
+	}
+}
+
+// Finish records the final status of the target along with how long it ran.
+func (r *TargetResult) Finish(status Status, reason string) {
+	r.Status = status
+	r.Reason = reason
+	r.Duration = time.Since(r.Started).Seconds()
+}
+
+// Finish records the final status of the run from the error it returned.
+func (r *RunReport) Finish(err error) {
+	r.Duration = time.Since(r.Started).Seconds()
+	r.Status = StatusPassed
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+	}
+}
+
+// Write writes the report to w in the given format: json, junit or tap.
+func (r *RunReport) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.WriteJSON(w)
+	case "junit":
+		return r.WriteJUnit(w)
+	case "tap":
+		return r.WriteTAP(w)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the report as indented JSON.
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type actionBlock struct {
+	name    string
+	results []ActionResult
+}
+
+// actionBlocks returns the action results of a target labelled by the block they ran in.
+func (r *TargetResult) actionBlocks() []actionBlock {
+	return []actionBlock{
+		{name: "action", results: r.Actions},
+		{name: "handler", results: r.Handlers},
+		{name: "rollback", results: r.Rollback},
+		{name: "on_failure", results: r.OnFailure},
+		{name: "always", results: r.Always},
+	}
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Every target becomes a test suite and every check
+// and action that ran, including on_failure and always actions, becomes a test case. Actions
+// skipped by their when condition count as skipped. A check that did not pass counts as skipped
+// when the target stopped quietly and as a failure when it cancelled the run. Targets that never
+// ran are reported as a single skipped test case.
+func (r *RunReport) WriteJUnit(w io.Writer) error {
+	suites := junitSuites{Name: "duck", Time: r.Duration}
+
+	for _, t := range r.Results {
+		suite := junitSuite{
+			Name:      t.Id,
+			Time:      t.Duration,
+			Timestamp: t.Started.Format("2006-01-02T15:04:05"),
+		}
+
+		for _, c := range t.Checks {
+			tc := junitCase{Name: fmt.Sprintf("check[%d] %s", c.Index, c.Type), ClassName: t.Id, Time: c.Duration}
+			switch {
+			case c.Status == StatusError:
+				tc.Error = &junitMessage{Message: c.Error}
+			case c.Status == StatusFailed && t.Status == StatusSkipped:
+				tc.Skipped = &junitMessage{Message: t.Reason}
+			case c.Status == StatusFailed:
+				tc.Failure = &junitMessage{Message: t.Reason}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		for _, block := range t.actionBlocks() {
+			for _, a := range block.results {
+				tc := junitCase{Name: fmt.Sprintf("%s[%d] %s", block.name, a.Index, a.Type), ClassName: t.Id, Time: a.Duration}
+				switch a.Status {
+				case StatusFailed:
+					tc.Failure = &junitMessage{Message: a.Error}
+				case StatusSkipped:
+					tc.Skipped = &junitMessage{Message: a.Reason}
+				}
+				suite.Cases = append(suite.Cases, tc)
+			}
+		}
+
+		if t.Status == StatusNotRun {
+			suite.Cases = append(suite.Cases, junitCase{Name: t.Id, ClassName: t.Id, Skipped: &junitMessage{Message: t.Reason}})
+		}
+
+		for _, tc := range suite.Cases {
+			suite.Tests++
+			switch {
+			case tc.Failure != nil:
+				suite.Failures++
+			case tc.Error != nil:
+				suite.Errors++
+			case tc.Skipped != nil:
+				suite.Skipped++
+			}
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteTAP writes the report as TAP version 13, one test point per check and action that ran
+// and one per target that never ran. Failures carry a YAML diagnostic block with the error.
+func (r *RunReport) WriteTAP(w io.Writer) error {
+	var lines []string
+	add := func(ok bool, description string, directive string, message string) {
+		status := "ok"
+		if !ok {
+			status = "not ok"
+		}
+		line := fmt.Sprintf("%s %d - %s", status, len(lines)+1, description)
+		if directive != "" {
+			line += " # " + directive
+		}
+		if message != "" && !ok {
+			line += fmt.Sprintf("\n  ---\n  message: %q\n  ...", message)
+		}
+		lines = append(lines, line)
+	}
+
+	for _, t := range r.Results {
+		for _, c := range t.Checks {
+			description := fmt.Sprintf("%s check[%d] %s", t.Id, c.Index, c.Type)
+			switch {
+			case c.Status == StatusPassed:
+				add(true, description, "", "")
+			case c.Status == StatusFailed && t.Status == StatusSkipped:
+				add(true, description, "SKIP "+t.Reason, "")
+			case c.Status == StatusError:
+				add(false, description, "", c.Error)
+			default:
+				add(false, description, "", t.Reason)
+			}
+		}
+		for _, block := range t.actionBlocks() {
+			for _, a := range block.results {
+				description := fmt.Sprintf("%s %s[%d] %s", t.Id, block.name, a.Index, a.Type)
+				if a.Status == StatusSkipped {
+					add(true, description, "SKIP "+a.Reason, "")
+					continue
+				}
+				add(a.Status == StatusPassed, description, "", a.Error)
+			}
+		}
+		if t.Status == StatusNotRun {
+			add(true, t.Id, "SKIP "+t.Reason, "")
+		}
+	}
+
+	out := fmt.Sprintf("TAP version 13\n1..%d\n", len(lines))
+	if len(lines) > 0 {
+		out += strings.Join(lines, "\n") + "\n"
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
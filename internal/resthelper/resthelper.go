@@ -0,0 +1,31 @@
+// Package resthelper holds helpers shared by the rest check and action for the responses they
+// get with github.com/go-resty/resty/v2.
+package resthelper
+
+import (
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Output returns the status code, body and headers of response, as the rest check and action
+// register them. Headers sent more than once are joined with a comma. A nil response, from a
+// request that was never made, has status code 0, an empty body and no headers.
+func Output(response *resty.Response) map[string]interface{} {
+	values := map[string]interface{}{
+		"status_code": 0,
+		"body":        "",
+		"headers":     map[string]interface{}{},
+	}
+	if response == nil {
+		return values
+	}
+	headers := make(map[string]interface{}, len(response.Header()))
+	for name, value := range response.Header() {
+		headers[name] = strings.Join(value, ", ")
+	}
+	values["status_code"] = response.StatusCode()
+	values["body"] = response.String()
+	values["headers"] = headers
+	return values
+}
@@ -0,0 +1,36 @@
+package resthelper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Duck", "one")
+		w.Header().Add("X-Duck", "two")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("quack"))
+	}))
+	defer server.Close()
+
+	response, err := resty.New().R().Get(server.URL)
+	require.NoError(t, err)
+
+	output := Output(response)
+	require.Equal(t, http.StatusAccepted, output["status_code"])
+	require.Equal(t, "quack", output["body"])
+	require.Equal(t, "one, two", output["headers"].(map[string]interface{})["X-Duck"])
+}
+
+func TestOutputWithoutResponse(t *testing.T) {
+	require.Equal(t, map[string]interface{}{
+		"status_code": 0,
+		"body":        "",
+		"headers":     map[string]interface{}{},
+	}, Output(nil))
+}
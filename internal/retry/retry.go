@@ -0,0 +1,63 @@
+// Package retry holds the retry policy shared by checks and actions and the loop that applies it.
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+const (
+	OnError  = "error"  // retry when Execute returns an error
+	OnFailed = "failed" // retry when the step finished but reported a failed status
+
+	maxBackoffShift = 10 // exponential backoff stops doubling after this many attempts
+)
+
+// Config is the retry policy for a single check or action. It is squashed into checks.Config
+// and actions.Config so the keys sit directly in a step's config block.
+type Config struct {
+	Retries     int           `mapstructure:"retries" default:"0" validate:"min=0"`
+	RetryDelay  time.Duration `mapstructure:"retryDelay" default:"1s" validate:"min=0"`
+	Backoff     string        `mapstructure:"backoff" default:"constant" validate:"oneof=constant exponential"`
+	RetryJitter bool          `mapstructure:"retryJitter" default:"false"`
+	RetryOn     []string      `mapstructure:"retryOn" default:"[\"error\"]" validate:"dive,oneof=error failed"`
+}
+
+// RetriesOn reports whether the policy retries on the given outcome, OnError or OnFailed.
+func (c Config) RetriesOn(outcome string) bool {
+	return slices.Contains(c.RetryOn, outcome)
+}
+
+// Delay returns how long to wait after the given attempt (starting at 1) before the next one.
+// Exponential backoff doubles the delay after every attempt. With jitter the delay is picked
+// at random between half and all of that value so retries from parallel targets spread out.
+func (c Config) Delay(attempt int) time.Duration {
+	delay := c.RetryDelay
+	if c.Backoff == "exponential" {
+		delay = delay << min(attempt-1, maxBackoffShift)
+	}
+	if c.RetryJitter && delay > 1 {
+		delay = delay/2 + rand.N(delay/2)
+	}
+	return delay
+}
+
+// Run calls attempt until it returns false or the retries are used up, waiting between attempts
+// according to the policy. attempt receives the attempt number starting at 1 and returns whether
+// the outcome should be retried. Run returns the number of attempts made, and the context error
+// if ctx was done while waiting for the next attempt.
+func (c Config) Run(ctx context.Context, attempt func(n int) bool) (int, error) {
+	for n := 1; ; n++ {
+		if !attempt(n) || n > c.Retries {
+			return n, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return n, ctx.Err()
+		case <-time.After(c.Delay(n)):
+		}
+	}
+}
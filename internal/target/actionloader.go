@@ -7,15 +7,32 @@ import (
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/actions"
 	dummyaction "github.com/mad-weaver/duck/internal/actions/dummy"
+	fileaction "github.com/mad-weaver/duck/internal/actions/file"
 	localstateaction "github.com/mad-weaver/duck/internal/actions/localstate"
 	printaction "github.com/mad-weaver/duck/internal/actions/print"
 	restaction "github.com/mad-weaver/duck/internal/actions/rest"
 	shellaction "github.com/mad-weaver/duck/internal/actions/shell"
 	sleepaction "github.com/mad-weaver/duck/internal/actions/sleep"
 	templateaction "github.com/mad-weaver/duck/internal/actions/template"
+	"github.com/mad-weaver/duck/internal/condition"
 )
 
+// LoadAction hydrates the action described by k according to its type and checks its when
+// condition, if any, compiles.
 func (t *Target) LoadAction(ctx context.Context, k *koanf.Koanf) (actions.Action, error) {
+	action, err := t.loadAction(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	if when := action.GetConfig().When; when != "" {
+		if _, err := condition.Compile(when); err != nil {
+			return nil, fmt.Errorf("invalid when condition: %w", err)
+		}
+	}
+	return action, nil
+}
+
+func (t *Target) loadAction(ctx context.Context, k *koanf.Koanf) (actions.Action, error) {
 	switch k.String("type") {
 	case "dummy":
 		return dummyaction.NewAction(ctx, k)
@@ -23,6 +40,8 @@ func (t *Target) LoadAction(ctx context.Context, k *koanf.Koanf) (actions.Action
 		return printaction.NewAction(ctx, k)
 	case "sleep":
 		return sleepaction.NewAction(ctx, k)
+	case "file":
+		return fileaction.NewAction(ctx, k)
 	case "localstate":
 		return localstateaction.NewAction(ctx, k)
 	case "rest":
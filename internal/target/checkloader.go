@@ -9,6 +9,7 @@ import (
 	croncheck "github.com/mad-weaver/duck/internal/checks/cron"
 	dummycheck "github.com/mad-weaver/duck/internal/checks/dummy"
 	filecheck "github.com/mad-weaver/duck/internal/checks/file"
+	groupcheck "github.com/mad-weaver/duck/internal/checks/group"
 	localstatecheck "github.com/mad-weaver/duck/internal/checks/localstate"
 	restcheck "github.com/mad-weaver/duck/internal/checks/rest"
 	shellcheck "github.com/mad-weaver/duck/internal/checks/shell"
@@ -28,6 +29,8 @@ func (t *Target) LoadCheck(ctx context.Context, k *koanf.Koanf) (checks.Check, e
 		return shellcheck.NewCheck(ctx, k)
 	case "rest":
 		return restcheck.NewCheck(ctx, k)
+	case "group":
+		return groupcheck.NewCheck(ctx, k, t.LoadCheck)
 	default:
 		return nil, fmt.Errorf("unknown check type: %s", k.String("type"))
 	}
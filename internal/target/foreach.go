@@ -0,0 +1,195 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/actions"
+	"github.com/mad-weaver/duck/internal/vars"
+)
+
+// foreachKey holds the item and key a step was expanded for, along with the loop it came from.
+// It is kept in the expanded definition so templates that can only be rendered when the step
+// runs still see .item and .key.
+const foreachKey = "_foreach"
+
+// expand returns the step definitions def stands for. A definition with a foreach key, holding a
+// list or a map, is expanded into one definition per element with .item set to the element and
+// .key to its index or map key, map keys in sorted order. Strings referencing .item or .key are
+// rendered right away, along with any variables they use. A parallel key lets the expanded
+// steps run concurrently, at most that many at once. loop identifies the definition in its
+// list so the expanded steps can be told apart from those of a neighbouring loop.
+func expand(ctx context.Context, def *koanf.Koanf, loop int) ([]*koanf.Koanf, error) {
+	if !def.Exists("foreach") {
+		return []*koanf.Koanf{def}, nil
+	}
+
+	type element struct {
+		key  interface{}
+		item interface{}
+	}
+	var elements []element
+	switch items := def.Get("foreach").(type) {
+	case []interface{}:
+		for i, item := range items {
+			elements = append(elements, element{key: i, item: item})
+		}
+	case map[string]interface{}:
+		keys := slices.Collect(maps.Keys(items))
+		sort.Strings(keys)
+		for _, key := range keys {
+			elements = append(elements, element{key: key, item: items[key]})
+		}
+	default:
+		return nil, fmt.Errorf("foreach must be a list or a map, got %v", items)
+	}
+
+	parallel := def.Int("parallel")
+	if parallel < 0 {
+		return nil, fmt.Errorf("parallel must be greater than or equal to 0, got %d", parallel)
+	}
+
+	store := vars.FromContext(ctx)
+	var defs []*koanf.Koanf
+	for _, e := range elements {
+		raw := def.Raw()
+		delete(raw, "foreach")
+		delete(raw, "parallel")
+		raw[foreachKey] = map[string]interface{}{
+			"item":     e.item,
+			"key":      e.key,
+			"loop":     loop,
+			"parallel": parallel,
+		}
+
+		instance := koanf.New(def.Delim())
+		for key, value := range raw {
+			if err := instance.Set(key, value); err != nil {
+				return nil, err
+			}
+		}
+
+		data := map[string]interface{}{"vars": store.Values(), "item": e.item, "key": e.key}
+		instance, err := vars.Render(instance, data, store.Deferred())
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand foreach item %v: %w", e.key, err)
+		}
+		defs = append(defs, instance)
+	}
+	return defs, nil
+}
+
+// stepData returns the data the templates of a step are rendered against when it runs: the
+// variables of the run, for an on_failure or rollback step the target that failed and why, and
+// for a step expanded by foreach its item and key.
+func stepData(ctx context.Context, def *koanf.Koanf) map[string]interface{} {
+	data := map[string]interface{}{"vars": vars.FromContext(ctx).Values()}
+	if failure, ok := actions.FailureFromContext(ctx); ok {
+		data["failure"] = map[string]interface{}{"target": failure.Target, "reason": failure.Reason}
+	}
+	if def.Exists(foreachKey) {
+		data["item"] = def.Get(foreachKey + def.Delim() + "item")
+		data["key"] = def.Get(foreachKey + def.Delim() + "key")
+	}
+	return data
+}
+
+// stepKey returns the foreach key a step was expanded for, empty for steps that were not.
+func stepKey(def *koanf.Koanf) string {
+	if !def.Exists(foreachKey) {
+		return ""
+	}
+	return fmt.Sprint(def.Get(foreachKey + def.Delim() + "key"))
+}
+
+// batch is a run of consecutive steps that are started together, at most parallel at a time.
+type batch struct {
+	steps    []int
+	parallel int
+}
+
+// batches groups the steps of a list so the steps expanded from a foreach with parallel set
+// form one batch, every other step runs on its own.
+func batches(defs []*koanf.Koanf) []batch {
+	var out []batch
+	for i, def := range defs {
+		parallel := def.Int(foreachKey + def.Delim() + "parallel")
+		loop := def.Int(foreachKey + def.Delim() + "loop")
+		if n := len(out); n > 0 && parallel > 1 && out[n-1].parallel == parallel {
+			prev := defs[out[n-1].steps[0]]
+			if prev.Exists(foreachKey) && prev.Int(foreachKey+prev.Delim()+"loop") == loop {
+				out[n-1].steps = append(out[n-1].steps, i)
+				continue
+			}
+		}
+		if parallel < 1 {
+			parallel = 1
+		}
+		out = append(out, batch{steps: []int{i}, parallel: parallel})
+	}
+	return out
+}
+
+// run calls fn for every step in the batch, at most b.parallel at a time, and waits for all of
+// them. fn returns true when its step failed in a way that stops the target, the context the
+// other steps run with is then cancelled and no further step is started. run returns the steps
+// that were not started or were still running when that happened.
+func (b batch) run(ctx context.Context, fn func(ctx context.Context, i int) bool) map[int]bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	stopped := false
+	interrupted := make(map[int]bool)
+	step := func(i int) {
+		stop := fn(ctx, i)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case stopped:
+			interrupted[i] = true
+		case stop:
+			stopped = true
+			cancel()
+		}
+	}
+
+	if len(b.steps) == 1 || b.parallel <= 1 {
+		for _, i := range b.steps {
+			if stopped {
+				interrupted[i] = true
+				continue
+			}
+			step(i)
+		}
+		return interrupted
+	}
+
+	sem := make(chan struct{}, b.parallel)
+	var wg sync.WaitGroup
+	for _, i := range b.steps {
+		sem <- struct{}{}
+		mu.Lock()
+		if stopped {
+			interrupted[i] = true
+			mu.Unlock()
+			<-sem
+			continue
+		}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			step(i)
+		}(i)
+	}
+	wg.Wait()
+	return interrupted
+}
@@ -0,0 +1,95 @@
+package target
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+// Schedule describes when a target runs in daemon mode. A target can run every Interval, on the
+// ticks of a Cron expression read in Timezone, or both, in which case whichever comes first wins.
+// Each run is pushed back by a random delay of up to Jitter so targets sharing a schedule don't
+// all start at once.
+type Schedule struct {
+	Interval time.Duration `mapstructure:"interval" validate:"min=0"`
+	Cron     string        `mapstructure:"cron"`     // cron expression as understood by github.com/adhocore/gronx
+	Timezone string        `mapstructure:"timezone"` // IANA name the cron expression is read in, UTC if empty
+	Jitter   time.Duration `mapstructure:"jitter" validate:"min=0"`
+	location *time.Location
+}
+
+// init checks the schedule can ever fire and loads its timezone.
+func (s *Schedule) init() error {
+	if s.Interval == 0 && s.Cron == "" {
+		return fmt.Errorf("schedule needs an interval, a cron expression or both")
+	}
+	if s.Cron != "" && !gronx.IsValid(s.Cron) {
+		return fmt.Errorf("invalid cron expression: %q", s.Cron)
+	}
+	s.location = time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone %q: %w", s.Timezone, err)
+		}
+		s.location = loc
+	}
+	return nil
+}
+
+// Next returns when the target should run next, given the time now and the time the last run
+// started, zero if it never ran. An interval schedule runs straight away the first time and then
+// every Interval after the last start, or now if that has already passed. A cron schedule runs on
+// the next tick after now.
+func (s *Schedule) Next(now time.Time, last time.Time) (time.Time, error) {
+	var next time.Time
+	if s.Interval > 0 {
+		next = now
+		if !last.IsZero() && last.Add(s.Interval).After(now) {
+			next = last.Add(s.Interval)
+		}
+	}
+	if s.Cron != "" {
+		location := s.location
+		if location == nil {
+			location = time.UTC
+		}
+		tick, err := gronx.NextTickAfter(s.Cron, now.In(location), false)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to compute next tick of %q: %w", s.Cron, err)
+		}
+		if next.IsZero() || tick.Before(next) {
+			next = tick
+		}
+	}
+	if s.Jitter > 0 {
+		next = next.Add(rand.N(s.Jitter))
+	}
+	return next, nil
+}
+
+// String describes the schedule in words, such as "every 30s or cron 0 3 * * * (Europe/Paris)".
+func (s *Schedule) String() string {
+	var parts []string
+	if s.Interval > 0 {
+		parts = append(parts, fmt.Sprintf("every %s", s.Interval))
+	}
+	if s.Cron != "" {
+		cron := fmt.Sprintf("cron %s", s.Cron)
+		if s.Timezone != "" {
+			cron += fmt.Sprintf(" (%s)", s.Timezone)
+		}
+		parts = append(parts, cron)
+	}
+	description := strings.Join(parts, " or ")
+	if description == "" {
+		description = "continuously"
+	}
+	if s.Jitter > 0 {
+		description += fmt.Sprintf(" with up to %s jitter", s.Jitter)
+	}
+	return description
+}
@@ -0,0 +1,66 @@
+package target
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleNext(t *testing.T) {
+	now := time.Date(2026, 7, 1, 0, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule Schedule
+		last     time.Time
+		want     time.Time
+	}{
+		{"interval first run", Schedule{Interval: time.Hour}, time.Time{}, now},
+		{"interval after last", Schedule{Interval: time.Hour}, now.Add(-10 * time.Minute), now.Add(50 * time.Minute)},
+		{"interval overdue", Schedule{Interval: time.Hour}, now.Add(-2 * time.Hour), now},
+		{"cron", Schedule{Cron: "0 3 * * *"}, time.Time{}, time.Date(2026, 7, 1, 3, 0, 0, 0, time.UTC)},
+		// 03:00 in Paris is 01:00 UTC in summer
+		{"cron in timezone", Schedule{Cron: "0 3 * * *", Timezone: "Europe/Paris"}, time.Time{}, time.Date(2026, 7, 1, 1, 0, 0, 0, time.UTC)},
+		{"cron ignores last", Schedule{Cron: "0 3 * * *"}, now.Add(-time.Minute), time.Date(2026, 7, 1, 3, 0, 0, 0, time.UTC)},
+		{"both cron earlier", Schedule{Interval: time.Hour, Cron: "*/15 * * * *"}, now.Add(-10 * time.Minute), now.Add(10 * time.Minute)},
+		{"both interval earlier", Schedule{Interval: time.Hour, Cron: "0 3 * * *"}, now.Add(-10 * time.Minute), now.Add(50 * time.Minute)},
+		{"both first run", Schedule{Interval: time.Hour, Cron: "*/15 * * * *"}, time.Time{}, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.schedule.init())
+			got, err := tt.schedule.Next(now, tt.last)
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestScheduleNextJitter(t *testing.T) {
+	now := time.Date(2026, 7, 1, 0, 5, 0, 0, time.UTC)
+	s := Schedule{Interval: time.Hour, Jitter: 10 * time.Second}
+	require.NoError(t, s.init())
+
+	for range 100 {
+		got, err := s.Next(now, time.Time{})
+		require.NoError(t, err)
+		require.False(t, got.Before(now), "jitter moved %s before %s", got, now)
+		require.True(t, got.Before(now.Add(s.Jitter)), "jitter moved %s past %s", got, now.Add(s.Jitter))
+	}
+}
+
+func TestScheduleInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"empty", Schedule{}},
+		{"invalid cron", Schedule{Cron: "every minute"}},
+		{"unknown timezone", Schedule{Cron: "0 3 * * *", Timezone: "Mars/Olympus_Mons"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.schedule.init())
+		})
+	}
+}
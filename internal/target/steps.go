@@ -0,0 +1,270 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/mad-weaver/duck/internal/actions"
+	"github.com/mad-weaver/duck/internal/checks"
+	"github.com/mad-weaver/duck/internal/condition"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
+	"github.com/mad-weaver/duck/internal/retry"
+	"github.com/mad-weaver/duck/internal/vars"
+)
+
+// runCheck executes the i-th check, retrying it according to its retry policy, and returns
+// the result of the last attempt along with the error Execute returned on that attempt. A check
+// whose definition references registered values is loaded again from its rendered definition
+// first, and its outcome is registered when the check sets register.
+func (t *Target) runCheck(ctx context.Context, i int, def *koanf.Koanf, check checks.Check) (report.CheckResult, error) {
+	result := report.CheckResult{
+		Index: i,
+		Key:   stepKey(def),
+		Type:  def.String("type"),
+	}
+
+	if data := stepData(ctx, def); vars.References(def, slices.Collect(maps.Keys(data))...) {
+		rendered, err := vars.Render(def, data, nil)
+		if err == nil {
+			check, err = t.LoadCheck(ctx, rendered)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to resolve check: %w", err)
+			result.Status, result.Error = report.StatusError, err.Error()
+			return result, err
+		}
+	}
+
+	cfg := check.GetConfig()
+	result.Invert = cfg.Invert
+
+	var err error
+	started := time.Now()
+	result.Attempts, _ = cfg.Run(ctx, func(attempt int) bool {
+		result.Status, result.Error = report.StatusPassed, ""
+		stepCtx, cancel := stepContext(ctx, cfg.Timeout)
+		err = t.timeoutError(ctx, stepCtx, cfg.Timeout, check.Execute(stepCtx))
+		cancel()
+		switch {
+		case err != nil:
+			result.Status, result.Error = report.StatusError, err.Error()
+		case !check.Check():
+			result.Status = report.StatusFailed
+		}
+
+		again := (result.Status == report.StatusError && cfg.RetriesOn(retry.OnError)) ||
+			(result.Status == report.StatusFailed && cfg.RetriesOn(retry.OnFailed))
+		if again && attempt <= cfg.Retries {
+			slog.Warn("Check did not pass, retrying", "id", t.Id, "check", i, "type", result.Type,
+				"attempt", attempt, "retries", cfg.Retries, "status", result.Status, "error", err)
+		}
+		return again
+	})
+	result.Duration = time.Since(started).Seconds()
+	if reporter, ok := check.(checks.ChildReporter); ok {
+		result.Children = reporter.Children()
+	}
+	t.register(ctx, cfg.Register, result.Status, check)
+
+	return result, err
+}
+
+// runAction executes the i-th action of a block, retrying it according to its retry policy, and returns
+// the result of the last attempt along with the error Execute returned on that attempt. An
+// action that reports a failed status without an error still counts as passed once its
+// retries are used up. Registered values are resolved and registered as for runCheck.
+func (t *Target) runAction(ctx context.Context, i int, def *koanf.Koanf, action actions.Action) (report.ActionResult, error) {
+	result := report.ActionResult{
+		Index: i,
+		Key:   stepKey(def),
+		Type:  def.String("type"),
+	}
+
+	if data := stepData(ctx, def); vars.References(def, slices.Collect(maps.Keys(data))...) {
+		rendered, err := vars.Render(def, data, nil)
+		if err == nil {
+			action, err = t.LoadAction(ctx, rendered)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to resolve action: %w", err)
+			result.Status, result.Error = report.StatusFailed, err.Error()
+			return result, err
+		}
+	}
+
+	cfg := action.GetConfig()
+	if cfg.When != "" {
+		run, err := condition.Evaluate(cfg.When, t.conditionData(ctx))
+		if err != nil {
+			err = fmt.Errorf("failed to evaluate when condition: %w", err)
+			result.Status, result.Error = report.StatusFailed, err.Error()
+			return result, err
+		}
+		if !run {
+			slog.Info("Skipping action, when condition is false", "id", t.Id, "action", i, "type", result.Type, "when", cfg.When)
+			result.Status, result.Reason = report.StatusSkipped, fmt.Sprintf("when condition is false: %s", cfg.When)
+			t.register(ctx, cfg.Register, result.Status, nil)
+			return result, nil
+		}
+	}
+
+	if actions.CheckMode(ctx) {
+		return t.dryRunAction(ctx, i, result, action)
+	}
+
+	var err error
+	failedStatus := false
+	started := time.Now()
+	result.Attempts, _ = cfg.Run(ctx, func(attempt int) bool {
+		result.Status, result.Error = report.StatusPassed, ""
+		stepCtx, cancel := stepContext(ctx, cfg.Timeout)
+		err = t.timeoutError(ctx, stepCtx, cfg.Timeout, action.Execute(stepCtx))
+		cancel()
+		if err != nil {
+			result.Status, result.Error = report.StatusFailed, err.Error()
+		}
+
+		failedStatus = false
+		if reporter, ok := action.(actions.StatusReporter); ok && err == nil {
+			failedStatus = reporter.Failed()
+		}
+
+		again := (err != nil && cfg.RetriesOn(retry.OnError)) ||
+			(failedStatus && cfg.RetriesOn(retry.OnFailed))
+		if again && attempt <= cfg.Retries {
+			slog.Warn("Action did not succeed, retrying", "id", t.Id, "action", i, "type", result.Type,
+				"attempt", attempt, "retries", cfg.Retries, "failed_status", failedStatus, "error", err)
+		}
+		return again
+	})
+	result.Duration = time.Since(started).Seconds()
+	// an action whose last attempt reported a failed status, such as a shell command exiting
+	// non-zero, is not taken to have changed anything.
+	if err == nil && !failedStatus {
+		result.Changed = true
+		if reporter, ok := action.(actions.ChangeReporter); ok {
+			changes := reporter.Result()
+			result.Changed, result.Diff, result.Description = changes.Changed, changes.Diff, changes.Description
+		}
+		if result.Changed {
+			slog.Info("Action changed something", "id", t.Id, "action", i, "type", result.Type)
+			if result.Diff != "" {
+				slog.Debug("Action diff", "id", t.Id, "action", i, "diff", result.Diff)
+			}
+		}
+	}
+	t.register(ctx, cfg.Register, result.Status, action)
+
+	return result, err
+}
+
+// dryRunAction previews the i-th action of a block in check mode, recording what it would change
+// in result without retrying. Actions that cannot preview themselves are skipped with a warning.
+func (t *Target) dryRunAction(ctx context.Context, i int, result report.ActionResult, action actions.Action) (report.ActionResult, error) {
+	cfg := action.GetConfig()
+	runner, ok := action.(actions.DryRunner)
+	if !ok {
+		slog.Warn("Action cannot run in check mode, skipping", "id", t.Id, "action", i, "type", result.Type)
+		result.Status, result.Reason = report.StatusSkipped, "action cannot run in check mode"
+		t.register(ctx, cfg.Register, result.Status, nil)
+		return result, nil
+	}
+
+	started := time.Now()
+	stepCtx, cancel := stepContext(ctx, cfg.Timeout)
+	changes, err := runner.DryRun(stepCtx)
+	err = t.timeoutError(ctx, stepCtx, cfg.Timeout, err)
+	cancel()
+	result.Attempts, result.Duration = 1, time.Since(started).Seconds()
+	if err != nil {
+		result.Status, result.Error = report.StatusFailed, err.Error()
+		return result, err
+	}
+
+	result.Status = report.StatusPassed
+	result.Changed, result.Diff, result.Description = changes.Changed, changes.Diff, changes.Description
+	slog.Info("Check mode", "id", t.Id, "action", i, "type", result.Type, "changed", result.Changed, "would", result.Description)
+	// the diff goes through the logger rather than stdout, where parallel targets would interleave
+	// it and it would corrupt a report written with --report -.
+	if result.Diff != "" {
+		slog.Info("Check mode diff", "id", t.Id, "action", i, "diff", result.Diff)
+	}
+	t.register(ctx, cfg.Register, result.Status, nil)
+	return result, nil
+}
+
+// conditionData returns the data when conditions are evaluated against: the variables of the
+// run, the environment, host facts and the results of the checks and actions of the target so far.
+func (t *Target) conditionData(ctx context.Context) map[string]interface{} {
+	var checkResults, actionResults []interface{}
+	for _, c := range t.Result.Checks {
+		checkResults = append(checkResults, map[string]interface{}{
+			"index": c.Index, "key": c.Key, "type": c.Type, "status": string(c.Status),
+		})
+	}
+	for _, a := range t.Result.Actions {
+		actionResults = append(actionResults, map[string]interface{}{
+			"index": a.Index, "key": a.Key, "type": a.Type, "status": string(a.Status), "changed": a.Changed,
+		})
+	}
+	return map[string]interface{}{
+		"vars":    vars.FromContext(ctx).Values(),
+		"env":     condition.Environ(),
+		"host":    condition.Facts(),
+		"checks":  checkResults,
+		"actions": actionResults,
+	}
+}
+
+// register stores the status of a step, along with its output when it reports one, under name
+// in the run's variable store. An empty name registers nothing.
+func (t *Target) register(ctx context.Context, name string, status report.Status, step interface{}) {
+	if name == "" {
+		return
+	}
+
+	values := map[string]interface{}{"status": string(status)}
+	if reporter, ok := step.(vars.OutputReporter); ok {
+		for key, value := range reporter.Output() {
+			values[key] = value
+		}
+	}
+	slog.Debug("Registering step output", "id", t.Id, "name", name)
+	vars.FromContext(ctx).Set(name, values)
+}
+
+// stepContext derives a context bounded by timeout for a target run or a single attempt of a
+// check or action. A timeout of 0 adds no bound beyond ctx.
+func stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timeoutError turns the outcome of an attempt into an error wrapping duckerr.ErrTimeout when the
+// attempt ran out of time, whether the step's own timeout or the target's expired. Steps that are
+// killed on timeout don't always return an error themselves, so the deadline alone decides.
+func (t *Target) timeoutError(ctx context.Context, stepCtx context.Context, timeout time.Duration, err error) error {
+	if !errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	var reason error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = fmt.Errorf("%w: target %s did not finish within %s", duckerr.ErrTimeout, t.Id, t.Config.Timeout)
+	} else {
+		reason = fmt.Errorf("%w: step did not finish within %s", duckerr.ErrTimeout, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", reason, err)
+	}
+	return reason
+}
@@ -2,32 +2,57 @@ package target
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/knadh/koanf/v2"
 	"github.com/mad-weaver/duck/internal/actions"
 	"github.com/mad-weaver/duck/internal/checks"
 	"github.com/mad-weaver/duck/internal/confighelper"
+	"github.com/mad-weaver/duck/internal/duckerr"
+	"github.com/mad-weaver/duck/internal/report"
 )
 
+// cleanupTimeout bounds the rollbacks, on_failure and always actions of a run together. They run
+// even once the run is cancelled, so this is what keeps a stuck cleanup from holding up shutdown.
+const cleanupTimeout = time.Minute
+
 type Target struct {
-	Id           string           `mapstructure:"id"`
-	Checks       []checks.Check   `mapstructure:"-"`
-	Actions      []actions.Action `mapstructure:"-"`
-	Cleared      bool             `default:"false"`
-	Config       Config           `mapstructure:"config"`
-	Dependencies []string         `mapstructure:"dependencies"`
-	mu           sync.Mutex
+	Id            string               `mapstructure:"id"`
+	Checks        []checks.Check       `mapstructure:"-"`
+	Actions       []actions.Action     `mapstructure:"-"`
+	OnFailure     []actions.Action     `mapstructure:"-"` // run when a check or action fails the target
+	Always        []actions.Action     `mapstructure:"-"` // run after every run of the target, like a finally block
+	Handlers      []actions.Action     `mapstructure:"-"` // run once after the actions if an action notifying them changed something
+	Rollbacks     []actions.Action     `mapstructure:"-"` // rollback of each action, nil for actions without one
+	Cleared       bool                 `default:"false"`
+	Config        Config               `mapstructure:"config"`
+	Dependencies  []string             `mapstructure:"dependencies"`
+	Tags          []string             `mapstructure:"tags"`     // labels used to select targets with --tags and --skip-tags
+	Schedule      *Schedule            `mapstructure:"schedule"` // when the target runs in daemon mode, nil to follow --daemon-interval
+	Source        string               `mapstructure:"-"`        // url of the duckfile the target was loaded from
+	Result        *report.TargetResult `mapstructure:"-"`        // outcome of the last Run, nil until the target runs
+	checkDefs     []*koanf.Koanf       // definitions the checks were loaded from, rendered again when they reference .vars
+	actionDefs    []*koanf.Koanf
+	onFailureDefs []*koanf.Koanf
+	alwaysDefs    []*koanf.Koanf
+	handlerDefs   []*koanf.Koanf
+	rollbackDefs  []*koanf.Koanf
+	succeeded     []int // actions that succeeded in the current run, rolled back if a later one fails
+	mu            sync.Mutex
 }
 
 type Config struct {
-	CancelOnCheckFailure  *bool `mapstructure:"cancelOnCheckFailure"`
-	CancelOnActionFailure *bool `mapstructure:"cancelOnActionFailure" default:"true"`
-	ExitOnCheckFailure    *bool `mapstructure:"exitOnCheckFailure"`
-	ExitOnActionFailure   *bool `mapstructure:"exitOnActionFailure"`
+	CancelOnCheckFailure  *bool         `mapstructure:"cancelOnCheckFailure"`
+	CancelOnActionFailure *bool         `mapstructure:"cancelOnActionFailure" default:"true"`
+	ExitOnCheckFailure    *bool         `mapstructure:"exitOnCheckFailure"`
+	ExitOnActionFailure   *bool         `mapstructure:"exitOnActionFailure"`
+	Timeout               time.Duration `mapstructure:"timeout" validate:"min=0"`      // bounds the whole run of the target, written with a unit such as "5m", 0 means no timeout
+	OnlyIfChanged         bool          `mapstructure:"onlyIfChanged" default:"false"` // skip the target unless a dependency changed something
 }
 
 func NewTarget(ctx context.Context, k *koanf.Koanf) (*Target, error) {
@@ -38,28 +63,146 @@ func NewTarget(ctx context.Context, k *koanf.Koanf) (*Target, error) {
 	if err := configHelper.Load(t, k, "", "mapstructure"); err != nil {
 		return nil, err
 	}
+	if t.Schedule != nil {
+		if err := t.Schedule.init(); err != nil {
+			return nil, fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
 
 	slog.Debug("Loading checks", "target", t)
-	for _, check := range k.Slices("checks") {
-		check, err := t.LoadCheck(ctx, check)
+	for i, def := range k.Slices("checks") {
+		defs, err := expand(ctx, def, i)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to load checks %d: %w", i, err)
+		}
+		for _, def := range defs {
+			check, err := t.LoadCheck(ctx, def)
+			if err != nil {
+				return nil, err
+			}
+			t.Checks = append(t.Checks, check)
+			t.checkDefs = append(t.checkDefs, def)
 		}
-		t.Checks = append(t.Checks, check)
 	}
 
 	slog.Debug("Loading actions", "target", t)
-	for _, action := range k.Slices("actions") {
-		action, err := t.LoadAction(ctx, action)
+	var err error
+	if t.Actions, t.actionDefs, err = t.loadActions(ctx, k, "actions"); err != nil {
+		return nil, err
+	}
+	if err := t.loadRollbacks(ctx); err != nil {
+		return nil, err
+	}
+	if t.OnFailure, t.onFailureDefs, err = t.loadActions(ctx, k, "on_failure"); err != nil {
+		return nil, err
+	}
+	if t.Always, t.alwaysDefs, err = t.loadActions(ctx, k, "always"); err != nil {
+		return nil, err
+	}
+	if t.Handlers, t.handlerDefs, err = t.loadActions(ctx, k, "handlers"); err != nil {
+		return nil, err
+	}
+	if err := t.validateNotify(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// loadRollbacks loads the rollback action defined in each action entry, if any. A rollback is a
+// plain action: its own rollback is ignored and it cannot notify handlers.
+func (t *Target) loadRollbacks(ctx context.Context) error {
+	t.Rollbacks = make([]actions.Action, len(t.Actions))
+	t.rollbackDefs = make([]*koanf.Koanf, len(t.Actions))
+	for i, def := range t.actionDefs {
+		if !def.Exists("rollback") {
+			continue
+		}
+		// the rollback is taken out of the action so it is only rendered when it runs, after the
+		// action registered anything it refers to.
+		rollbackDef := def.Cut("rollback")
+		def.Delete("rollback")
+		if def.Exists(foreachKey) {
+			rollbackDef.Set(foreachKey, def.Get(foreachKey))
+		}
+		rollback, err := t.LoadAction(ctx, rollbackDef)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to load rollback of action %d: %w", i, err)
 		}
-		t.Actions = append(t.Actions, action)
+		if len(rollback.GetConfig().Notify) > 0 {
+			return fmt.Errorf("rollback of action %d: notify is only supported in actions", i)
+		}
+		t.Rollbacks[i], t.rollbackDefs[i] = rollback, rollbackDef
 	}
+	return nil
+}
 
-	return t, nil
+// validateNotify checks every handler has a name and every handler notified by an action exists.
+// Only actions notify handlers, so notify is rejected in the other blocks.
+func (t *Target) validateNotify() error {
+	names := make(map[string]struct{})
+	for i, def := range t.handlerDefs {
+		if def.String("name") == "" {
+			return fmt.Errorf("handler %d has no name", i)
+		}
+		names[def.String("name")] = struct{}{}
+	}
+
+	for i, action := range t.Actions {
+		for _, name := range action.GetConfig().Notify {
+			if _, ok := names[name]; !ok {
+				return fmt.Errorf("action %d notifies unknown handler %s", i, name)
+			}
+		}
+	}
+	blocks := map[string][]actions.Action{"on_failure": t.OnFailure, "always": t.Always, "handlers": t.Handlers}
+	for block, list := range blocks {
+		for i, action := range list {
+			if len(action.GetConfig().Notify) > 0 {
+				return fmt.Errorf("%s %d: notify is only supported in actions", block, i)
+			}
+		}
+	}
+	return nil
 }
 
+// loadActions loads the list of actions found under key, expanding foreach entries, and returns
+// them along with their definitions.
+func (t *Target) loadActions(ctx context.Context, k *koanf.Koanf, key string) ([]actions.Action, []*koanf.Koanf, error) {
+	var loaded []actions.Action
+	var defs []*koanf.Koanf
+	for i, def := range k.Slices(key) {
+		expanded, err := expand(ctx, def, i)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load %s %d: %w", key, i, err)
+		}
+		for _, def := range expanded {
+			action, err := t.LoadAction(ctx, def)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load %s %d: %w", key, i, err)
+			}
+			loaded = append(loaded, action)
+			defs = append(defs, def)
+		}
+	}
+	return loaded, defs, nil
+}
+
+// Run executes the target's checks and, if they all pass, its actions. A failed check or action
+// either clears the target quietly or returns an error wrapping duckerr.ErrCheckFailed or
+// duckerr.ErrActionFailed, depending on the cancel and exit settings. When exit is requested the
+// error also wraps duckerr.ErrExitRequested so the caller can stop everything else in flight.
+// Once the checks and actions are done the on_failure actions run if the target failed, then the
+// always actions run; neither changes the outcome of the target. The outcome of every check and
+// action is recorded in t.Result. Steps expanded from a foreach with parallel set run together,
+// their outcomes are then handled in order as if they had run one after the other, except that a
+// step failing with cancel or exit set stops its siblings and those it interrupts are left out of
+// t.Result. Handlers notified by actions that changed something run once each after the actions,
+// in the order they are defined, and fail the target like actions do. A target with onlyIfChanged
+// set is skipped unless a dependency changed something, see WithUpstreamChanged. When a failed
+// action or handler cancels the target, the rollbacks of the actions that had succeeded run in
+// reverse order before the on_failure actions. Rollbacks and cleanup actions still run when ctx
+// is cancelled, for up to cleanupTimeout.
 func (t *Target) Run(ctx context.Context) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -71,70 +214,275 @@ func (t *Target) Run(ctx context.Context) error {
 		return nil
 	}
 
+	t.Result = report.NewTargetResult(t.Id, t.Source)
+
 	if ctx.Err() != nil {
 		slog.Debug("Context cancelled, skipping target", "id", t.Id)
-		return fmt.Errorf("context cancelled, likely by termination signal/interrupt")
+		err := fmt.Errorf("%w: target %s not started", duckerr.ErrInterrupted, t.Id)
+		t.Result.Finish(report.StatusNotRun, err.Error())
+		return err
 	}
 
-	for _, check := range t.Checks {
-		if err := check.Execute(ctx); err != nil {
-			return err
-		}
+	if t.Config.OnlyIfChanged && !UpstreamChangedFromContext(ctx) {
+		slog.Info("No dependency changed anything, skipping target", "id", t.Id)
+		t.Result.Finish(report.StatusSkipped, "no dependency changed anything")
+		t.Cleared = true
+		return nil
+	}
 
-		chkcfg := check.GetConfig()
-		// Check has failed, handle it.
-		if !check.Check() {
-			slog.Debug("Check failed")
+	t.succeeded = nil
+	status, reason, err := t.run(ctx)
 
-			shouldExit := (chkcfg.ExitOnFailure != nil && *chkcfg.ExitOnFailure) ||
-				(chkcfg.ExitOnFailure == nil && t.Config.ExitOnCheckFailure != nil && *t.Config.ExitOnCheckFailure)
+	// cleanup is detached from ctx and the target's own deadline so a target that timed out, was
+	// interrupted or was stopped by an exit request still gets to clean up, within cleanupTimeout.
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	if status == report.StatusCancelled && errors.Is(reason, duckerr.ErrActionFailed) {
+		t.Result.Rollback = t.runRollbacks(actions.WithFailure(cleanupCtx, actions.Failure{Target: t.Id, Reason: reason.Error()}))
+	}
+	if status == report.StatusCancelled || status == report.StatusFailed {
+		t.Result.OnFailure = t.runHandlers(actions.WithFailure(cleanupCtx, actions.Failure{Target: t.Id, Reason: reason.Error()}),
+			"on_failure", t.OnFailure, t.onFailureDefs)
+	}
+	t.Result.Always = t.runHandlers(cleanupCtx, "always", t.Always, t.alwaysDefs)
 
-			if shouldExit {
-				slog.Debug("ExitOnCheckFailure set, terminating duck immediately", "id", t.Id)
-				os.Exit(1)
-			}
+	for _, result := range slices.Concat(t.Result.Actions, t.Result.Handlers) {
+		t.Result.Changed = t.Result.Changed || result.Changed
+	}
+
+	if reason != nil {
+		t.Result.Finish(status, reason.Error())
+	} else {
+		t.Result.Finish(status, "")
+	}
+	if status != report.StatusCancelled {
+		t.Cleared = true
+	}
+	return err
+}
+
+// Reset forgets the outcome of the last run so the target runs again, as daemon mode does before
+// every scheduled run.
+func (t *Target) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Cleared = false
+	t.Result = nil
+}
 
-			shouldCancel := (chkcfg.CancelOnFailure != nil && *chkcfg.CancelOnFailure) ||
-				(chkcfg.CancelOnFailure == nil && t.Config.CancelOnCheckFailure != nil && *t.Config.CancelOnCheckFailure)
+// run executes the checks and actions of the target and returns the status the target ended
+// with, the reason it did not pass, and the error Run should return.
+func (t *Target) run(ctx context.Context) (report.Status, error, error) {
+	// runCtx bounds the checks and actions by the target timeout, ctx is kept to tell an
+	// interrupt apart from a timeout.
+	runCtx, cancel := stepContext(ctx, t.Config.Timeout)
+	defer cancel()
 
-			if shouldCancel {
-				slog.Debug("Cancelling target", "id", t.Id)
-				return fmt.Errorf("check failed, cancelling run")
+	checkResults := make([]report.CheckResult, len(t.Checks))
+	checkErrs := make([]error, len(t.Checks))
+	for _, b := range batches(t.checkDefs) {
+		interrupted := b.run(runCtx, func(ctx context.Context, i int) bool {
+			checkResults[i], checkErrs[i] = t.runCheck(ctx, i, t.checkDefs[i], t.Checks[i])
+			exit, cancel := t.checkPolicy(i)
+			return checkResults[i].Status != report.StatusPassed && (exit || cancel || checkErrs[i] != nil)
+		})
+		for _, i := range b.steps {
+			if interrupted[i] && checkResults[i].Status != report.StatusPassed {
+				continue
+			}
+			if status, reason, err, done := t.checkOutcome(ctx, i, checkResults[i], checkErrs[i]); done {
+				return status, reason, err
+			}
+		}
+	}
+	slog.Debug("all checks passed, executing actions")
+	actionResults := make([]report.ActionResult, len(t.Actions))
+	actionErrs := make([]error, len(t.Actions))
+	notified := make(map[string]struct{})
+	for _, b := range batches(t.actionDefs) {
+		interrupted := b.run(runCtx, func(ctx context.Context, i int) bool {
+			actionResults[i], actionErrs[i] = t.runAction(ctx, i, t.actionDefs[i], t.Actions[i])
+			exit, cancel := t.actionPolicy(t.Actions[i])
+			return actionErrs[i] != nil && (exit || cancel)
+		})
+		// every step of the batch is done by now, so the ones that passed are recorded for
+		// rollback even when an earlier step in the batch fails the target.
+		for _, i := range b.steps {
+			if actionResults[i].Status == report.StatusPassed {
+				t.succeeded = append(t.succeeded, i)
+			}
+		}
+		for _, i := range b.steps {
+			if interrupted[i] && actionResults[i].Status != report.StatusPassed {
+				continue
 			}
+			t.Result.Actions = append(t.Result.Actions, actionResults[i])
+			if status, reason, err, done := t.actionOutcome(ctx, "action", i, t.Actions[i], actionResults[i], actionErrs[i]); done {
+				return status, reason, err
+			}
+			if actionResults[i].Changed {
+				for _, name := range t.Actions[i].GetConfig().Notify {
+					notified[name] = struct{}{}
+				}
+			}
+		}
+	}
 
-			slog.Debug("check failed, but no cancellation or exit set, moving to next target")
-			t.Cleared = true
-			return nil
+	for i, handler := range t.Handlers {
+		if _, ok := notified[t.handlerDefs[i].String("name")]; !ok {
+			continue
 		}
+		slog.Debug("Running notified handler", "id", t.Id, "handler", t.handlerDefs[i].String("name"))
+		result, err := t.runAction(runCtx, i, t.handlerDefs[i], handler)
+		t.Result.Handlers = append(t.Result.Handlers, result)
+		if status, reason, err, done := t.actionOutcome(ctx, "handler", i, handler, result, err); done {
+			return status, reason, err
+		}
+	}
+	slog.Debug("all actions passed, marking target cleared and moving onward.", "id", t.Id)
+	return report.StatusPassed, nil, nil
+}
+
+// checkOutcome records the result of the i-th check and decides whether the target stops there,
+// returning the status, reason and error run should return when it does.
+func (t *Target) checkOutcome(ctx context.Context, i int, result report.CheckResult, err error) (report.Status, error, error, bool) {
+	t.Result.Checks = append(t.Result.Checks, result)
 
+	if result.Status == report.StatusPassed {
+		return "", nil, nil, false
 	}
-	slog.Debug("all checks passed, executing actions")
-	for _, action := range t.Actions {
-		if err := action.Execute(ctx); err != nil {
-			actioncfg := action.GetConfig()
 
-			shouldExit := (actioncfg.ExitOnFailure != nil && *actioncfg.ExitOnFailure) ||
-				(actioncfg.ExitOnFailure == nil && t.Config.ExitOnActionFailure != nil && *t.Config.ExitOnActionFailure)
+	// Check has failed, handle it.
+	if ctx.Err() != nil {
+		err := fmt.Errorf("%w: check %d (%s) of target %s: %w", duckerr.ErrInterrupted, i, result.Type, t.Id, ctx.Err())
+		return report.StatusCancelled, err, err, true
+	}
+	reason := fmt.Errorf("%w: check %d (%s) of target %s", duckerr.ErrCheckFailed, i, result.Type, t.Id)
+	if err != nil {
+		reason = fmt.Errorf("%w: %w", reason, err)
+	}
+	slog.Debug("Check failed", "id", t.Id, "check", i, "error", err)
 
-			if shouldExit {
-				slog.Debug("ExitOnActionFailure set, terminating duck immediately", "id", t.Id)
-				os.Exit(1)
-			}
+	shouldExit, shouldCancel := t.checkPolicy(i)
 
-			shouldCancel := (actioncfg.CancelOnFailure != nil && *actioncfg.CancelOnFailure) ||
-				(actioncfg.CancelOnFailure == nil && t.Config.CancelOnActionFailure != nil && *t.Config.CancelOnActionFailure)
+	if shouldExit {
+		slog.Debug("ExitOnCheckFailure set, terminating duck", "id", t.Id)
+		return report.StatusCancelled, reason, fmt.Errorf("%w: %w", duckerr.ErrExitRequested, reason), true
+	}
 
-			if shouldCancel {
-				slog.Debug("Cancelling target", "id", t.Id)
-				return fmt.Errorf("action failed, cancelling run")
-			}
+	if shouldCancel || err != nil {
+		slog.Debug("Cancelling target", "id", t.Id)
+		return report.StatusCancelled, reason, reason, true
+	}
+
+	slog.Debug("check failed, but no cancellation or exit set, moving to next target")
+	return report.StatusSkipped, reason, nil, true
+}
 
-			slog.Warn("Action failed, but no cancellation or exit set, Setting target to cleared and moving to next target", "id", t.Id)
-			t.Cleared = true
-			return nil
+// actionOutcome decides whether the target stops after the i-th action or handler, labelled by
+// block in errors, as checkOutcome does for checks.
+func (t *Target) actionOutcome(ctx context.Context, block string, i int, action actions.Action, result report.ActionResult, err error) (report.Status, error, error, bool) {
+	if err == nil {
+		return "", nil, nil, false
+	}
+	if ctx.Err() != nil {
+		err := fmt.Errorf("%w: %s %d (%s) of target %s: %w", duckerr.ErrInterrupted, block, i, result.Type, t.Id, err)
+		return report.StatusCancelled, err, err, true
+	}
+	reason := fmt.Errorf("%w: %s %d (%s) of target %s: %w", duckerr.ErrActionFailed, block, i, result.Type, t.Id, err)
+	shouldExit, shouldCancel := t.actionPolicy(action)
+
+	if shouldExit {
+		slog.Debug("ExitOnActionFailure set, terminating duck", "id", t.Id)
+		return report.StatusCancelled, reason, fmt.Errorf("%w: %w", duckerr.ErrExitRequested, reason), true
+	}
+
+	if shouldCancel {
+		slog.Debug("Cancelling target", "id", t.Id)
+		return report.StatusCancelled, reason, reason, true
+	}
+
+	slog.Warn("Action failed, but no cancellation or exit set, Setting target to cleared and moving to next target", "id", t.Id, "error", err)
+	return report.StatusFailed, reason, nil, true
+}
+
+// checkPolicy returns whether a failure of the i-th check requests an exit and whether it cancels
+// the target, the check's own settings taking precedence over the target's.
+func (t *Target) checkPolicy(i int) (bool, bool) {
+	chkcfg := t.Checks[i].GetConfig()
+	shouldExit := (chkcfg.ExitOnFailure != nil && *chkcfg.ExitOnFailure) ||
+		(chkcfg.ExitOnFailure == nil && t.Config.ExitOnCheckFailure != nil && *t.Config.ExitOnCheckFailure)
+	shouldCancel := (chkcfg.CancelOnFailure != nil && *chkcfg.CancelOnFailure) ||
+		(chkcfg.CancelOnFailure == nil && t.Config.CancelOnCheckFailure != nil && *t.Config.CancelOnCheckFailure)
+	return shouldExit, shouldCancel
+}
+
+// actionPolicy is checkPolicy for an action or handler.
+func (t *Target) actionPolicy(action actions.Action) (bool, bool) {
+	actioncfg := action.GetConfig()
+	shouldExit := (actioncfg.ExitOnFailure != nil && *actioncfg.ExitOnFailure) ||
+		(actioncfg.ExitOnFailure == nil && t.Config.ExitOnActionFailure != nil && *t.Config.ExitOnActionFailure)
+	shouldCancel := (actioncfg.CancelOnFailure != nil && *actioncfg.CancelOnFailure) ||
+		(actioncfg.CancelOnFailure == nil && t.Config.CancelOnActionFailure != nil && *t.Config.CancelOnActionFailure)
+	return shouldExit, shouldCancel
+}
+
+// runRollbacks runs the rollbacks of the actions that succeeded in this run, last action first.
+// Like runHandlers every rollback is attempted and failures are logged and recorded without
+// changing the outcome of the target. Each result carries the index of the action rolled back.
+func (t *Target) runRollbacks(ctx context.Context) []report.ActionResult {
+	results := []report.ActionResult{}
+	for _, i := range slices.Backward(t.succeeded) {
+		if t.Rollbacks[i] == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			slog.Warn("Cleanup timed out, skipping remaining rollbacks", "id", t.Id, "timeout", cleanupTimeout)
+			break
+		}
+
+		slog.Info("Rolling back action", "id", t.Id, "action", i)
+		result, err := t.runAction(ctx, i, t.rollbackDefs[i], t.Rollbacks[i])
+		if err != nil {
+			slog.Error("Rollback failed", "id", t.Id, "action", i, "type", result.Type, "error", err)
 		}
+		results = append(results, result)
 	}
-	slog.Debug("all actions passed, marking target cleared and moving onward.", "id", t.Id)
-	t.Cleared = true
-	return nil
+	return results
+}
+
+// runHandlers runs a block of cleanup actions such as on_failure or always. Every action in the
+// block is attempted even if an earlier one fails, failures are logged and recorded but do not
+// change the outcome of the target. Nothing more runs once ctx, which Run bounds by
+// cleanupTimeout, is done.
+func (t *Target) runHandlers(ctx context.Context, block string, handlers []actions.Action, defs []*koanf.Koanf) []report.ActionResult {
+	results := []report.ActionResult{}
+	for i, action := range handlers {
+		if ctx.Err() != nil {
+			slog.Warn("Cleanup timed out, skipping remaining actions", "id", t.Id, "block", block, "timeout", cleanupTimeout)
+			break
+		}
+
+		slog.Debug("Running action", "id", t.Id, "block", block, "action", i)
+		result, err := t.runAction(ctx, i, defs[i], action)
+		if err != nil {
+			slog.Error("Action failed", "id", t.Id, "block", block, "action", i, "type", result.Type, "error", err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+type upstreamKey struct{}
+
+// WithUpstreamChanged returns a copy of ctx recording whether a dependency of the target about
+// to run changed something, which decides whether a target with onlyIfChanged set runs.
+func WithUpstreamChanged(ctx context.Context, changed bool) context.Context {
+	return context.WithValue(ctx, upstreamKey{}, changed)
+}
+
+// UpstreamChangedFromContext returns the value attached to ctx by WithUpstreamChanged, false if none.
+func UpstreamChangedFromContext(ctx context.Context) bool {
+	changed, _ := ctx.Value(upstreamKey{}).(bool)
+	return changed
 }
@@ -0,0 +1,313 @@
+// Package vars holds duckfile variables and the values steps register during a run, and renders
+// the templates that reference them, such as {{ .vars.region }} or {{ .vars.build.stdout }},
+// into target definitions.
+package vars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// OutputReporter is implemented by checks and actions that produce output worth registering,
+// such as the stdout of a shell command or the body of a rest call.
+type OutputReporter interface {
+	Output() map[string]interface{} // Returns the output of the last Execute
+}
+
+// Store is the run-scoped set of variables, seeded with the duckfile variables and extended with
+// the values registered by steps under the name given in register.
+// It is safe for use by targets running in parallel. A nil Store holds nothing.
+type Store struct {
+	mu      sync.RWMutex
+	values  map[string]interface{}
+	pending []string
+}
+
+func NewStore() *Store {
+	return &Store{values: make(map[string]interface{})}
+}
+
+// Set stores value under name, replacing whatever was stored there before.
+func (s *Store) Set(name string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[name] = value
+}
+
+// Values returns a snapshot of every registered value.
+func (s *Store) Values() map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.values)
+}
+
+// Defer marks field chains, such as vars.build, whose values only exist once the run is under
+// way. Templates referencing them are left for the step to render when it runs.
+func (s *Store) Defer(paths ...string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, paths...)
+}
+
+// Deferred returns the field chains marked with Defer.
+func (s *Store) Deferred() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.pending)
+}
+
+type storeKey struct{}
+
+// WithStore returns a copy of ctx carrying the store s.
+func WithStore(ctx context.Context, s *Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, s)
+}
+
+// FromContext returns the store attached to ctx by WithStore, or nil if there is none.
+func FromContext(ctx context.Context) *Store {
+	s, _ := ctx.Value(storeKey{}).(*Store)
+	return s
+}
+
+// action matches a template action, reference matches a field chain such as .vars.build.stdout
+// inside one and whole matches a string made of a single field chain.
+var (
+	action    = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	reference = regexp.MustCompile(`(?:^|[^\w$)\]])\.([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)`)
+	whole     = regexp.MustCompile(`^\{\{-?\s*\.([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\s*-?\}\}$`)
+)
+
+// references returns the field chains referenced by the template actions in s, such as
+// vars.build.stdout or item.
+func references(s string) []string {
+	var refs []string
+	for _, a := range action.FindAllStringSubmatch(s, -1) {
+		for _, r := range reference.FindAllStringSubmatch(a[1], -1) {
+			refs = append(refs, r[1])
+		}
+	}
+	return refs
+}
+
+// root returns the first element of a field chain.
+func root(ref string) string {
+	first, _, _ := strings.Cut(ref, ".")
+	return first
+}
+
+// covers reports whether ref is path or lies below it.
+func covers(path string, ref string) bool {
+	return ref == path || strings.HasPrefix(ref, path+".")
+}
+
+// References reports whether any string in k references one of the given roots, such as vars
+// or item. Strings referencing other fields, such as a docker --format argument, don't count.
+func References(k *koanf.Koanf, roots ...string) bool {
+	found := false
+	walk(k.Raw(), func(s string) (interface{}, error) {
+		for _, ref := range references(s) {
+			found = found || slices.Contains(roots, root(ref))
+		}
+		return s, nil
+	})
+	return found
+}
+
+// Render returns a copy of k in which every string referencing one of the roots of data, such as
+// .vars, has been executed as a text/template against data. Strings referencing any of the
+// deferred field chains, such as vars.build or item, are left as they are so they can be
+// rendered once those values exist, and strings that reference none of the roots in data or
+// deferred are left alone so literal braces pass through untouched. A string made of a single
+// reference to a value that is not a string, such as a list, is replaced by the value itself.
+// Referencing a variable that is neither in data nor deferred is an error.
+func Render(k *koanf.Koanf, data map[string]interface{}, deferred []string) (*koanf.Koanf, error) {
+	raw, err := walk(k.Raw(), func(s string) (interface{}, error) {
+		refs := references(s)
+		templated := false
+		for _, ref := range refs {
+			for _, path := range deferred {
+				if covers(path, ref) || root(path) == ref {
+					return s, nil
+				}
+			}
+			if _, ok := data[root(ref)]; !ok {
+				continue
+			}
+			templated = true
+			if values, ok := data[root(ref)].(map[string]interface{}); ok && root(ref) == "vars" {
+				_, name, _ := strings.Cut(ref, ".")
+				if _, defined := values[root(name)]; !defined {
+					return nil, fmt.Errorf("undefined variable %q in %q", root(name), s)
+				}
+			}
+		}
+		if !templated {
+			return s, nil
+		}
+
+		if m := whole.FindStringSubmatch(s); m != nil {
+			if value, ok := lookup(data, m[1]); ok {
+				if _, isString := value.(string); !isString {
+					return value, nil
+				}
+			}
+		}
+
+		tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %q: %w", s, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render template %q: %w", s, err)
+		}
+		return b.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := koanf.New(k.Delim())
+	if err := rendered.Load(mapProvider(raw.(map[string]interface{})), nil); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// lookup follows a field chain through nested maps.
+func lookup(data map[string]interface{}, ref string) (interface{}, bool) {
+	var value interface{} = data
+	for _, field := range strings.Split(ref, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Registered returns the names that the checks and actions found anywhere in k register their
+// outcome under. The checks inside a group are left out, as only the group itself registers.
+func Registered(k *koanf.Koanf) []string {
+	var names []string
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if config, ok := v["config"].(map[string]interface{}); ok {
+				if register, ok := config["register"].(string); ok && register != "" {
+					names = append(names, register)
+				}
+			}
+			if v["type"] == "group" {
+				return
+			}
+			for _, value := range v {
+				visit(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				visit(value)
+			}
+		}
+	}
+	visit(k.Raw())
+	return names
+}
+
+// Override layers variable overrides on top of values: first environment variables named
+// DUCK_VAR_<name>, then key=value assignments such as those given with --set. Environment names
+// match an existing variable regardless of case, so DUCK_VAR_REGION overrides region, and
+// otherwise add the variable under the lowercased name. environ is in the form returned by
+// os.Environ.
+func Override(values map[string]interface{}, environ []string, assignments []string) error {
+	for _, env := range environ {
+		key, value, _ := strings.Cut(env, "=")
+		key, ok := strings.CutPrefix(key, "DUCK_VAR_")
+		if !ok || key == "" {
+			continue
+		}
+		target := strings.ToLower(key)
+		for existing := range values {
+			if strings.EqualFold(existing, key) {
+				target = existing
+				break
+			}
+		}
+		values[target] = value
+	}
+
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid variable assignment %q, expected key=value", assignment)
+		}
+		values[key] = value
+	}
+	return nil
+}
+
+// walk returns a copy of v with fn applied to every string found in nested maps and slices.
+func walk(v interface{}, fn func(string) (interface{}, error)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			w, err := walk(value, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = w
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			w, err := walk(value, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = w
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// mapProvider is a koanf provider for an already parsed nested map.
+type mapProvider map[string]interface{}
+
+func (m mapProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("mapProvider does not support ReadBytes")
+}
+
+func (m mapProvider) Read() (map[string]interface{}, error) {
+	return m, nil
+}
@@ -5,8 +5,11 @@ import (
 	"os"
 
 	cmd "github.com/mad-weaver/duck/cmd/duck"
+	"github.com/mad-weaver/duck/internal/duckerr"
 )
 
+// main runs the duck CLI and translates the error it returns into a process exit code,
+// see duckerr for the meaning of each code.
 func main() {
 	app := cmd.NewApp()
 	if app == nil {
@@ -15,7 +18,7 @@ func main() {
 	}
 	if err := app.Run(os.Args); err != nil {
 		slog.Error(err.Error())
-		os.Exit(1)
+		os.Exit(duckerr.ExitCode(err))
 	}
 	os.Exit(0)
 }